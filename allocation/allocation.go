@@ -0,0 +1,180 @@
+// Package allocation aggregates the assets held on magnetis by category,
+// issuer or instrument type and compares them to a target allocation.
+package allocation
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alfredosegundo/magnetis-crawler/magnetis"
+)
+
+// Dimension is the asset attribute used to group the amounts
+type Dimension int
+
+// Dimensions an allocation can be broken down by
+const (
+	Category Dimension = iota
+	Issuer
+	InstrumentType
+)
+
+var dimensions = [...]string{
+	"category",
+	"issuer",
+	"instrument",
+}
+
+func (d Dimension) String() string { return dimensions[d] }
+
+// ParseDimension returns the dimension with the given name.
+func ParseDimension(name string) (Dimension, error) {
+	for i, d := range dimensions {
+		if d == name {
+			return Dimension(i), nil
+		}
+	}
+	return 0, fmt.Errorf("Unknown dimension %q, use one of %s", name, strings.Join(dimensions[:], ", "))
+}
+
+func (d Dimension) key(asset magnetis.Asset) string {
+	switch d {
+	case Issuer:
+		return asset.Issuer
+	case InstrumentType:
+		return asset.InstrumentTypeName
+	default:
+		return asset.CategoryKey
+	}
+}
+
+// A Slice is the amount held on one value of a dimension.
+type Slice struct {
+	Key     string
+	Amount  float64
+	Percent float64
+}
+
+func (s Slice) String() string {
+	return fmt.Sprintf("%s\t%.2f\t%.2f%%", s.Key, s.Amount, s.Percent)
+}
+
+// Breakdown sums the assets amount by the dimension, largest slices first.
+func Breakdown(assets []magnetis.Asset, dimension Dimension) (slices []Slice) {
+	amounts := make(map[string]float64)
+	var total float64
+	for _, asset := range assets {
+		amount, _ := strconv.ParseFloat(strings.TrimSpace(asset.Amount), 64)
+		amounts[dimension.key(asset)] += amount
+		total += amount
+	}
+	for key, amount := range amounts {
+		s := Slice{Key: key, Amount: amount}
+		if total > 0 {
+			s.Percent = amount / total * 100
+		}
+		slices = append(slices, s)
+	}
+	sort.Slice(slices, func(i, j int) bool {
+		if slices[i].Amount == slices[j].Amount {
+			return slices[i].Key < slices[j].Key
+		}
+		return slices[i].Amount > slices[j].Amount
+	})
+	return
+}
+
+// A Target maps dimension values to the desired percentage of the portfolio.
+type Target map[string]float64
+
+// ReadTarget reads a target allocation from a JSON file such as
+// {"fixed_income": 60, "stocks": 40}.
+func ReadTarget(file string) (target Target, err error) {
+	b, err := ioutil.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(b, &target); err != nil {
+		return nil, fmt.Errorf("Failed to parse target allocation %s: %v", file, err)
+	}
+	return
+}
+
+// A Deviation compares a slice of the portfolio to its target.
+type Deviation struct {
+	Key           string
+	Amount        float64
+	Percent       float64
+	TargetPercent float64
+	Difference    float64 // Amount to reach the target, negative when overweight
+}
+
+func (d Deviation) String() string {
+	return fmt.Sprintf("%s\t%.2f%%\t%.2f%%\t%+.2f", d.Key, d.Percent, d.TargetPercent, d.Difference)
+}
+
+// Compare returns the deviation of every slice and target key.
+func Compare(slices []Slice, target Target) (deviations []Deviation) {
+	var total float64
+	current := make(map[string]Slice)
+	for _, s := range slices {
+		total += s.Amount
+		current[s.Key] = s
+	}
+	keys := make([]string, 0, len(current))
+	for key := range current {
+		keys = append(keys, key)
+	}
+	for key := range target {
+		if _, ok := current[key]; !ok {
+			keys = append(keys, key)
+		}
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		s := current[key]
+		deviations = append(deviations, Deviation{
+			Key:           key,
+			Amount:        s.Amount,
+			Percent:       s.Percent,
+			TargetPercent: target[key],
+			Difference:    total*target[key]/100 - s.Amount,
+		})
+	}
+	return
+}
+
+// Rebalance splits a new contribution among the underweight slices so the
+// portfolio gets as close as possible to the target without selling.
+func Rebalance(slices []Slice, target Target, contribution float64) map[string]float64 {
+	total := contribution
+	for _, s := range slices {
+		total += s.Amount
+	}
+	current := make(map[string]float64)
+	for _, s := range slices {
+		current[s.Key] = s.Amount
+	}
+	needs := make(map[string]float64)
+	var needed float64
+	for key, percent := range target {
+		need := total*percent/100 - current[key]
+		if need > 0 {
+			needs[key] = need
+			needed += need
+		}
+	}
+	contributions := make(map[string]float64)
+	if needed == 0 {
+		return contributions
+	}
+	for key, need := range needs {
+		contributions[key] = math.Round(need*contribution/needed*100) / 100
+	}
+	return contributions
+}
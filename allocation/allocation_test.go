@@ -0,0 +1,95 @@
+package allocation
+
+import (
+	"math"
+	"testing"
+
+	"github.com/alfredosegundo/magnetis-crawler/magnetis"
+)
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+var slices = []Slice{
+	{Key: "fixed_income", Amount: 600, Percent: 60},
+	{Key: "stocks", Amount: 300, Percent: 30},
+	{Key: "crypto", Amount: 100, Percent: 10}, // Held but not on the targets
+}
+
+func TestBreakdown(t *testing.T) {
+	assets := []magnetis.Asset{
+		{CategoryKey: "stocks", Issuer: "B", Amount: "300"},
+		{CategoryKey: "fixed_income", Issuer: "A", Amount: "250"},
+		{CategoryKey: "fixed_income", Issuer: "B", Amount: " 250 "},
+		{CategoryKey: "crypto", Issuer: "C", Amount: "200"},
+	}
+	want := []Slice{{"fixed_income", 500, 50}, {"stocks", 300, 30}, {"crypto", 200, 20}}
+	got := Breakdown(assets, Category)
+	if len(got) != len(want) {
+		t.Fatalf("Breakdown = %v, want %v", got, want)
+	}
+	for i := range want {
+		if got[i].Key != want[i].Key || !near(got[i].Amount, want[i].Amount) || !near(got[i].Percent, want[i].Percent) {
+			t.Errorf("Breakdown[%d] = %v, want %v", i, got[i], want[i])
+		}
+	}
+	if got := Breakdown(assets, Issuer); got[0].Key != "B" || got[1].Key != "A" || got[2].Key != "C" {
+		t.Errorf("Breakdown by issuer = %v, want B, then A and C tied by name", got)
+	}
+}
+
+func TestCompare(t *testing.T) {
+	tests := []struct {
+		name   string
+		target Target
+		want   map[string]float64 // Difference by key
+	}{
+		{"drift", Target{"fixed_income": 50, "stocks": 50},
+			map[string]float64{"crypto": -100, "fixed_income": -100, "stocks": 200}},
+		{"target not held", Target{"fixed_income": 50, "stocks": 30, "real_estate": 20},
+			map[string]float64{"crypto": -100, "fixed_income": -100, "stocks": 0, "real_estate": 200}},
+	}
+	for _, tt := range tests {
+		deviations := Compare(slices, tt.target)
+		if len(deviations) != len(tt.want) {
+			t.Errorf("%s: Compare = %v, want %v", tt.name, deviations, tt.want)
+			continue
+		}
+		for i, d := range deviations {
+			if i > 0 && deviations[i-1].Key > d.Key {
+				t.Errorf("%s: deviations not sorted by key: %v", tt.name, deviations)
+			}
+			if want, ok := tt.want[d.Key]; !ok || !near(d.Difference, want) || d.TargetPercent != tt.target[d.Key] {
+				t.Errorf("%s: %s difference %v target %v, want %v and %v", tt.name, d.Key, d.Difference, d.TargetPercent, want, tt.target[d.Key])
+			}
+		}
+	}
+}
+
+func TestRebalance(t *testing.T) {
+	tests := []struct {
+		name         string
+		target       Target
+		contribution float64
+		want         map[string]float64
+	}{
+		{"underweight only", Target{"fixed_income": 50, "stocks": 50}, 200, map[string]float64{"stocks": 200}},
+		{"not enough to reach the target", Target{"fixed_income": 50, "stocks": 50}, 100, map[string]float64{"stocks": 100}},
+		{"split by need", Target{"fixed_income": 50, "stocks": 30, "real_estate": 20}, 1000,
+			map[string]float64{"fixed_income": 363.64, "stocks": 272.73, "real_estate": 363.64}},
+		{"nothing needed", Target{"fixed_income": 60, "stocks": 30, "crypto": 10}, 0, map[string]float64{}},
+	}
+	for _, tt := range tests {
+		got := Rebalance(slices, tt.target, tt.contribution)
+		if len(got) != len(tt.want) {
+			t.Errorf("%s: Rebalance = %v, want %v", tt.name, got, tt.want)
+			continue
+		}
+		for key, want := range tt.want {
+			if !near(got[key], want) {
+				t.Errorf("%s: Rebalance[%s] = %v, want %v", tt.name, key, got[key], want)
+			}
+		}
+	}
+}
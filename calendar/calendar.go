@@ -0,0 +1,320 @@
+// Package calendar knows the brazilian national holidays and the B3
+// trading days and hours.
+package calendar
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Location is the time zone of B3, Brasília time.
+var Location = loadLocation()
+
+func loadLocation() *time.Location {
+	loc, err := time.LoadLocation("America/Sao_Paulo")
+	if err != nil {
+		return time.FixedZone("BRT", -3*60*60)
+	}
+	return loc
+}
+
+// A Holiday is a day without trading.
+type Holiday struct {
+	Date     time.Time
+	Name     string
+	National bool // False for days only B3 is closed
+}
+
+func (h Holiday) String() string {
+	return fmt.Sprintf("%s %s", h.Date.Format("2006-01-02"), h.Name)
+}
+
+// Easter returns the easter sunday of the year, computed with the
+// anonymous gregorian algorithm.
+func Easter(year int) time.Time {
+	a := year % 19
+	b, c := year/100, year%100
+	d, e := b/4, b%4
+	f := (b + 8) / 25
+	g := (b - f + 1) / 3
+	h := (19*a + b - d - g + 15) % 30
+	i, k := c/4, c%4
+	l := (32 + 2*e + 2*i - h - k) % 7
+	m := (a + 11*h + 22*l) / 451
+	month := (h + l - 7*m + 114) / 31
+	day := (h+l-7*m+114)%31 + 1
+	return time.Date(year, time.Month(month), day, 0, 0, 0, 0, Location)
+}
+
+// National returns the brazilian national holidays of the year, including
+// carnival and corpus christi which are observed nationwide.
+func National(year int) []Holiday {
+	date := func(month time.Month, day int) time.Time {
+		return time.Date(year, month, day, 0, 0, 0, 0, Location)
+	}
+	easter := Easter(year)
+	holidays := []Holiday{
+		{date(time.January, 1), "Confraternização Universal", true},
+		{easter.AddDate(0, 0, -48), "Carnaval", true},
+		{easter.AddDate(0, 0, -47), "Carnaval", true},
+		{easter.AddDate(0, 0, -2), "Sexta-feira Santa", true},
+		{date(time.April, 21), "Tiradentes", true},
+		{date(time.May, 1), "Dia do Trabalho", true},
+		{easter.AddDate(0, 0, 60), "Corpus Christi", true},
+		{date(time.September, 7), "Independência do Brasil", true},
+		{date(time.October, 12), "Nossa Senhora Aparecida", true},
+		{date(time.November, 2), "Finados", true},
+		{date(time.November, 15), "Proclamação da República", true},
+		{date(time.December, 25), "Natal", true},
+	}
+	if year >= 2024 {
+		holidays = append(holidays, Holiday{date(time.November, 20), "Dia Nacional de Zumbi e da Consciência Negra", true})
+	}
+	sort.Slice(holidays, func(i, j int) bool { return holidays[i].Date.Before(holidays[j].Date) })
+	return holidays
+}
+
+// B3 returns the days B3 doesn't trade in the year: the national holidays,
+// christmas eve and the last day of the year.
+func B3(year int) []Holiday {
+	holidays := append(National(year),
+		Holiday{Date: time.Date(year, time.December, 24, 0, 0, 0, 0, Location), Name: "Véspera de Natal"},
+		Holiday{Date: time.Date(year, time.December, 31, 0, 0, 0, 0, Location), Name: "Último dia do ano"},
+	)
+	sort.Slice(holidays, func(i, j int) bool { return holidays[i].Date.Before(holidays[j].Date) })
+	return holidays
+}
+
+// Session is the regular trading session, as offsets from midnight in
+// Brasília time.
+type Session struct {
+	Open  time.Duration
+	Close time.Duration
+}
+
+// DefaultSession is the B3 equities regular session, 10:00 to 17:00.
+var DefaultSession = Session{Open: 10 * time.Hour, Close: 17 * time.Hour}
+
+// A Calendar tells the trading days and hours. Overrides loaded from a
+// file take precedence over the computed holidays.
+type Calendar struct {
+	Session  Session
+	Holidays func(year int) []Holiday
+
+	mu        sync.Mutex
+	years     map[int]map[string]Holiday
+	overrides map[string]*Holiday // nil when the day is open
+}
+
+// New returns the B3 calendar.
+func New() *Calendar {
+	return &Calendar{Session: DefaultSession, Holidays: B3}
+}
+
+var defaultCalendar = New()
+
+// Load reads overrides from a file. Each line has a date as YYYY-MM-DD,
+// optionally followed by "open" for a computed holiday with trading, or by
+// the name of an extra holiday. Empty lines and lines starting with # are
+// ignored.
+func (c *Calendar) Load(path string) error {
+	f, err := os.Open(path)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" || strings.HasPrefix(text, "#") {
+			continue
+		}
+		fields := strings.SplitN(text, " ", 2)
+		day, err := time.ParseInLocation("2006-01-02", fields[0], Location)
+		if err != nil {
+			return fmt.Errorf("%s:%d: invalid date %q", path, line, fields[0])
+		}
+		name := "Feriado"
+		if len(fields) == 2 {
+			name = strings.TrimSpace(fields[1])
+		}
+		if name == "open" {
+			c.Open(day)
+		} else {
+			c.Close(day, name)
+		}
+	}
+	return scanner.Err()
+}
+
+// Close adds a holiday.
+func (c *Calendar) Close(day time.Time, name string) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.overrides == nil {
+		c.overrides = make(map[string]*Holiday)
+	}
+	c.overrides[key(day)] = &Holiday{Date: midnight(day), Name: name}
+}
+
+// Open removes a holiday.
+func (c *Calendar) Open(day time.Time) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if c.overrides == nil {
+		c.overrides = make(map[string]*Holiday)
+	}
+	c.overrides[key(day)] = nil
+}
+
+// Holiday returns the holiday on the day of t, if any.
+func (c *Calendar) Holiday(t time.Time) (Holiday, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	k := key(t)
+	if h, ok := c.overrides[k]; ok {
+		if h == nil {
+			return Holiday{}, false
+		}
+		return *h, true
+	}
+	year := t.Year()
+	if c.years == nil {
+		c.years = make(map[int]map[string]Holiday)
+	}
+	days, ok := c.years[year]
+	if !ok {
+		days = make(map[string]Holiday)
+		for _, h := range c.Holidays(year) {
+			days[key(h.Date)] = h
+		}
+		c.years[year] = days
+	}
+	h, ok := days[k]
+	return h, ok
+}
+
+// IsBusinessDay tells if the day of t is neither a weekend nor a holiday.
+func (c *Calendar) IsBusinessDay(t time.Time) bool {
+	if t.Weekday() == time.Saturday || t.Weekday() == time.Sunday {
+		return false
+	}
+	_, holiday := c.Holiday(t)
+	return !holiday
+}
+
+// AddBusinessDays moves t n business days forward, or backward when n is
+// negative, keeping its time of the day.
+func (c *Calendar) AddBusinessDays(t time.Time, n int) time.Time {
+	step := 1
+	if n < 0 {
+		step, n = -1, -n
+	}
+	for n > 0 {
+		t = t.AddDate(0, 0, step)
+		if c.IsBusinessDay(t) {
+			n--
+		}
+	}
+	return t
+}
+
+// NextBusinessDay returns the first business day after t.
+func (c *Calendar) NextBusinessDay(t time.Time) time.Time {
+	return c.AddBusinessDays(t, 1)
+}
+
+// PreviousBusinessDay returns the last business day before t.
+func (c *Calendar) PreviousBusinessDay(t time.Time) time.Time {
+	return c.AddBusinessDays(t, -1)
+}
+
+// BusinessDaysBetween counts the business days after from up to and
+// including to, negative when to is before from.
+func (c *Calendar) BusinessDaysBetween(from, to time.Time) int {
+	sign := 1
+	if to.Before(from) {
+		from, to, sign = to, from, -1
+	}
+	from, to = midnight(from), midnight(to)
+	days := 0
+	for d := from.AddDate(0, 0, 1); !d.After(to); d = d.AddDate(0, 0, 1) {
+		if c.IsBusinessDay(d) {
+			days++
+		}
+	}
+	return sign * days
+}
+
+// LastBusinessDay returns the last business day of the month.
+func (c *Calendar) LastBusinessDay(year int, month time.Month) time.Time {
+	first := time.Date(year, month+1, 1, 0, 0, 0, 0, Location)
+	return c.PreviousBusinessDay(first)
+}
+
+// IsOpen tells if the market is in its regular session at t.
+func (c *Calendar) IsOpen(t time.Time) bool {
+	t = t.In(Location)
+	if !c.IsBusinessDay(t) {
+		return false
+	}
+	offset := t.Sub(midnight(t))
+	return offset >= c.Session.Open && offset < c.Session.Close
+}
+
+// NextOpen returns when the next regular session opens after t, t itself
+// when the market is open.
+func (c *Calendar) NextOpen(t time.Time) time.Time {
+	if c.IsOpen(t) {
+		return t
+	}
+	t = t.In(Location)
+	day := midnight(t)
+	if t.Sub(day) >= c.Session.Open {
+		day = day.AddDate(0, 0, 1)
+	}
+	for !c.IsBusinessDay(day) {
+		day = day.AddDate(0, 0, 1)
+	}
+	return day.Add(c.Session.Open)
+}
+
+// IsBusinessDay tells if t is a B3 trading day.
+func IsBusinessDay(t time.Time) bool {
+	return defaultCalendar.IsBusinessDay(t)
+}
+
+// AddBusinessDays moves t n B3 trading days.
+func AddBusinessDays(t time.Time, n int) time.Time {
+	return defaultCalendar.AddBusinessDays(t, n)
+}
+
+// BusinessDaysBetween counts the B3 trading days after from up to to.
+func BusinessDaysBetween(from, to time.Time) int {
+	return defaultCalendar.BusinessDaysBetween(from, to)
+}
+
+// LastBusinessDay returns the last B3 trading day of the month.
+func LastBusinessDay(year int, month time.Month) time.Time {
+	return defaultCalendar.LastBusinessDay(year, month)
+}
+
+// IsOpen tells if B3 is in its regular session at t.
+func IsOpen(t time.Time) bool {
+	return defaultCalendar.IsOpen(t)
+}
+
+// midnight returns the start of the day of t. Days are taken from the
+// wall clock of t, so dates parsed in UTC keep their day.
+func midnight(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, Location)
+}
+
+func key(t time.Time) string {
+	return t.Format("2006-01-02")
+}
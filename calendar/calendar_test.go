@@ -0,0 +1,191 @@
+package calendar
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func day(value string) time.Time {
+	t, err := time.ParseInLocation("2006-01-02", value, Location)
+	if err != nil {
+		panic(err)
+	}
+	return t
+}
+
+func TestEaster(t *testing.T) {
+	tests := map[int]string{
+		2019: "2019-04-21",
+		2020: "2020-04-12",
+		2023: "2023-04-09",
+		2024: "2024-03-31",
+		2025: "2025-04-20",
+		2026: "2026-04-05",
+	}
+	for year, want := range tests {
+		if got := Easter(year).Format("2006-01-02"); got != want {
+			t.Errorf("Easter(%d) = %s, want %s", year, got, want)
+		}
+	}
+}
+
+func TestB3(t *testing.T) {
+	tests := []struct {
+		year int
+		want []string
+	}{
+		{2023, []string{
+			"2023-01-01", "2023-02-20", "2023-02-21", "2023-04-07", "2023-04-21", "2023-05-01", "2023-06-08",
+			"2023-09-07", "2023-10-12", "2023-11-02", "2023-11-15", "2023-12-24", "2023-12-25", "2023-12-31",
+		}},
+		{2024, []string{
+			"2024-01-01", "2024-02-12", "2024-02-13", "2024-03-29", "2024-04-21", "2024-05-01", "2024-05-30",
+			"2024-09-07", "2024-10-12", "2024-11-02", "2024-11-15", "2024-11-20", "2024-12-24", "2024-12-25", "2024-12-31",
+		}},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, h := range B3(tt.year) {
+			got = append(got, h.Date.Format("2006-01-02"))
+		}
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("B3(%d) = %v, want %v", tt.year, got, tt.want)
+		}
+	}
+}
+
+func TestIsBusinessDay(t *testing.T) {
+	tests := []struct {
+		day  string
+		want bool
+	}{
+		{"2024-02-09", true},  // Friday before carnival
+		{"2024-02-12", false}, // Carnival
+		{"2024-02-13", false}, // Carnival
+		{"2024-02-14", true},  // Ash wednesday
+		{"2024-02-17", false}, // Saturday
+		{"2023-11-20", true},  // Before the national holiday
+		{"2024-11-20", false}, // Consciência Negra
+		{"2024-12-24", false},
+		{"2024-12-31", false},
+	}
+	for _, tt := range tests {
+		if got := IsBusinessDay(day(tt.day)); got != tt.want {
+			t.Errorf("IsBusinessDay(%s) = %v, want %v", tt.day, got, tt.want)
+		}
+	}
+	utc := time.Date(2024, 2, 12, 0, 0, 0, 0, time.UTC)
+	if IsBusinessDay(utc) {
+		t.Errorf("IsBusinessDay(%v) should keep the day of a UTC date", utc)
+	}
+}
+
+func TestAddBusinessDays(t *testing.T) {
+	tests := []struct {
+		from string
+		n    int
+		want string
+	}{
+		{"2024-02-09", 1, "2024-02-14"},
+		{"2024-02-14", -1, "2024-02-09"},
+		{"2024-12-23", 1, "2024-12-26"},
+		{"2024-12-23", 3, "2024-12-30"},
+		{"2024-12-23", 4, "2025-01-02"},
+		{"2024-02-17", 0, "2024-02-17"},
+		{"2024-11-19", 1, "2024-11-21"},
+	}
+	for _, tt := range tests {
+		if got := AddBusinessDays(day(tt.from), tt.n).Format("2006-01-02"); got != tt.want {
+			t.Errorf("AddBusinessDays(%s, %d) = %s, want %s", tt.from, tt.n, got, tt.want)
+		}
+	}
+	if got := BusinessDaysBetween(day("2024-02-09"), day("2024-02-16")); got != 3 {
+		t.Errorf("BusinessDaysBetween over carnival = %d, want 3", got)
+	}
+	if got := BusinessDaysBetween(day("2024-02-16"), day("2024-02-09")); got != -3 {
+		t.Errorf("BusinessDaysBetween backwards = %d, want -3", got)
+	}
+}
+
+func TestLastBusinessDay(t *testing.T) {
+	tests := []struct {
+		year  int
+		month time.Month
+		want  string
+	}{
+		{2024, time.March, "2024-03-28"},
+		{2024, time.May, "2024-05-31"},
+		{2024, time.November, "2024-11-29"},
+		{2024, time.December, "2024-12-30"},
+		{2025, time.May, "2025-05-30"},
+	}
+	for _, tt := range tests {
+		if got := LastBusinessDay(tt.year, tt.month).Format("2006-01-02"); got != tt.want {
+			t.Errorf("LastBusinessDay(%d, %s) = %s, want %s", tt.year, tt.month, got, tt.want)
+		}
+	}
+}
+
+func TestSession(t *testing.T) {
+	at := func(value string, hour int) time.Time {
+		return day(value).Add(time.Duration(hour) * time.Hour)
+	}
+	tests := []struct {
+		t    time.Time
+		open bool
+	}{
+		{at("2024-02-14", 11), true},
+		{at("2024-02-14", 9), false},
+		{at("2024-02-14", 17), false},
+		{at("2024-02-13", 11), false},
+		{time.Date(2024, 2, 14, 14, 0, 0, 0, time.UTC), true},
+	}
+	for _, tt := range tests {
+		if got := IsOpen(tt.t); got != tt.open {
+			t.Errorf("IsOpen(%v) = %v, want %v", tt.t, got, tt.open)
+		}
+	}
+	if got, want := New().NextOpen(at("2024-02-09", 18)), at("2024-02-14", 10); !got.Equal(want) {
+		t.Errorf("NextOpen = %v, want %v", got, want)
+	}
+}
+
+func TestLoad(t *testing.T) {
+	dir, err := ioutil.TempDir("", "calendar")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "holidays.txt")
+	overrides := "# São Paulo\n\n2024-02-13 open\n2024-07-09 Revolução Constitucionalista\n2024-08-01\n"
+	if err = ioutil.WriteFile(path, []byte(overrides), 0600); err != nil {
+		t.Fatal(err)
+	}
+	c := New()
+	if err = c.Load(path); err != nil {
+		t.Fatal(err)
+	}
+	if !c.IsBusinessDay(day("2024-02-13")) {
+		t.Errorf("2024-02-13 should be open")
+	}
+	if h, ok := c.Holiday(day("2024-07-09")); !ok || h.Name != "Revolução Constitucionalista" {
+		t.Errorf("Holiday(2024-07-09) = %v, %v", h, ok)
+	}
+	if h, ok := c.Holiday(day("2024-08-01")); !ok || h.Name != "Feriado" {
+		t.Errorf("Holiday(2024-08-01) = %v, %v", h, ok)
+	}
+	if !New().IsBusinessDay(day("2024-07-09")) {
+		t.Errorf("overrides should not change other calendars")
+	}
+
+	if err = ioutil.WriteFile(path, []byte("2024-02-13 open\n2024-13-01\n"), 0600); err != nil {
+		t.Fatal(err)
+	}
+	if err = New().Load(path); err == nil || !strings.Contains(err.Error(), ":2:") {
+		t.Errorf("Load of an invalid date error = %v, want the line number", err)
+	}
+}
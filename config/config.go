@@ -0,0 +1,269 @@
+// Package config reads the crawler configuration file, which holds named
+// profiles for each magnetis account.
+package config
+
+import (
+	"fmt"
+	"io/ioutil"
+	"math"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alfredosegundo/magnetis-crawler/secrets"
+	"gopkg.in/yaml.v2"
+)
+
+// Credentials names the secrets holding the magnetis username and password.
+type Credentials struct {
+	User     string `yaml:"user"`
+	Password string `yaml:"password"`
+}
+
+// A Profile is the configuration of one magnetis account.
+type Profile struct {
+	Credentials      Credentials        `yaml:"credentials"`
+	UserID           string             `yaml:"user_id"`
+	SpreadsheetID    string             `yaml:"spreadsheet_id"`
+	Vault            string             `yaml:"vault"`
+	SecretsURL       string             `yaml:"secrets_url"`
+	QuotesURL        string             `yaml:"quotes_url"`
+	QuotesPriceField string             `yaml:"quotes_price_field"` // Path to the price in the quote API response, price when empty
+	QuotesCurrency   string             `yaml:"quotes_currency"`    // Currency of the quote API prices, BRL when empty
+	Store            string             `yaml:"store"`              // Local store directory, ~/.magnetis_crawler/data when empty
+	Sinks            []string           `yaml:"sinks"`              // Where --save writes, spreadsheet when empty
+	Layout           map[string]string  `yaml:"layout"`             // Spreadsheet tab names by content
+	Accounts         map[string]string  `yaml:"accounts"`           // Journal account names of the ledger export
+	Target           map[string]float64 `yaml:"target"`             // Target percentage of each asset category
+}
+
+// DaemonJob runs a crawler job on a cron expression.
+type DaemonJob struct {
+	Name        string `yaml:"name"`         // curve, applications, assets, plan, stocks or analytics
+	Schedule    string `yaml:"schedule"`     // Five field cron expression, e.g. "*/15 10-17 * * 1-5"
+	TradingDays bool   `yaml:"trading_days"` // Skip weekends and holidays
+	MarketHours bool   `yaml:"market_hours"` // Skip runs while B3 is closed
+}
+
+// Daemon configures the jobs run by the daemon command.
+type Daemon struct {
+	StatusFile string        `yaml:"status_file"` // ~/.magnetis_crawler/daemon.json when empty
+	Jitter     time.Duration `yaml:"jitter"`      // Random delay added to each start, e.g. 30s
+	Timezone   string        `yaml:"timezone"`    // Time zone of the schedules, e.g. America/Sao_Paulo
+	Holidays   []string      `yaml:"holidays"`    // Non trading days as YYYY-MM-DD
+	Calendar   string        `yaml:"calendar"`    // Holiday overrides file, see calendar.Load
+	Jobs       []DaemonJob   `yaml:"jobs"`
+}
+
+// Config holds the profiles of the configuration file.
+type Config struct {
+	DefaultProfile string                     `yaml:"default_profile"`
+	Profiles       map[string]Profile         `yaml:"profiles"`
+	Daemon         Daemon                     `yaml:"daemon"`
+	RiskProfiles   map[int]map[string]float64 `yaml:"risk_profiles"` // Target percentage of each asset category by plan risk level
+}
+
+// Sinks the crawled data can be saved to
+var Sinks = []string{"spreadsheet", "store"}
+
+// LayoutKeys are the names of the spreadsheet tabs, one for each content
+var LayoutKeys = []string{"curve", "applications", "invested", "assets", "plan", "stocks"}
+
+// AccountKeys are the journal accounts of the ledger export
+var AccountKeys = []string{"bank", "investments", "ir", "advisory_fees", "transaction_fees", "portfolio", "currency"}
+
+// Environment variables overriding the profile values. Each value accepts
+// the listed names, the first set wins.
+var (
+	UserIDEnv        = []string{"MAGNETIS_USER_ID"}
+	SpreadsheetIDEnv = []string{"GOOGLE_SPREADSHEET_ID", "SPREADSHEET_ID"}
+	VaultEnv         = []string{"MAGNETIS_VAULT"}
+	SecretsURLEnv    = []string{"SECRETS_URL"}
+	QuotesURLEnv     = []string{"QUOTES_URL"}
+	ProfileEnv       = "MAGNETIS_PROFILE"
+	PathEnv          = "MAGNETIS_CONFIG"
+)
+
+// DefaultPath returns the config file path, MAGNETIS_CONFIG or
+// ~/.magnetis_crawler/config.yaml.
+func DefaultPath() (string, error) {
+	if path, ok := os.LookupEnv(PathEnv); ok {
+		return path, nil
+	}
+	usr, err := user.Current()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(usr.HomeDir, ".magnetis_crawler", "config.yaml"), nil
+}
+
+// Load reads a config file. A missing file is an empty configuration.
+func Load(path string) (*Config, error) {
+	c := &Config{Profiles: make(map[string]Profile)}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return c, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = yaml.UnmarshalStrict(b, c); err != nil {
+		return nil, fmt.Errorf("Failed to parse config %s: %v", path, err)
+	}
+	for name, p := range c.Profiles {
+		if err = p.validate(); err != nil {
+			return nil, fmt.Errorf("Profile %s: %v", name, err)
+		}
+	}
+	for level, target := range c.RiskProfiles {
+		if err = validateTarget(target); err != nil {
+			return nil, fmt.Errorf("Risk profile %d: %v", level, err)
+		}
+	}
+	for _, day := range c.Daemon.Holidays {
+		if _, err = time.Parse("2006-01-02", day); err != nil {
+			return nil, fmt.Errorf("Daemon holiday %q must be YYYY-MM-DD", day)
+		}
+	}
+	return c, nil
+}
+
+// Profile returns the named profile. An empty name selects MAGNETIS_PROFILE,
+// then the default profile; when none is set an empty profile is returned.
+func (c *Config) Profile(name string) (Profile, error) {
+	if name == "" {
+		name = os.Getenv(ProfileEnv)
+	}
+	if name == "" {
+		name = c.DefaultProfile
+	}
+	if name == "" {
+		return Profile{}, nil
+	}
+	p, ok := c.Profiles[name]
+	if !ok {
+		return Profile{}, fmt.Errorf("Unknown profile %q, available: %s", name, strings.Join(c.Names(), ", "))
+	}
+	return p, nil
+}
+
+// Names returns the profile names.
+func (c *Config) Names() []string {
+	names := make([]string, 0, len(c.Profiles))
+	for name := range c.Profiles {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// WithEnv returns the profile with the values set on environment
+// variables, which take precedence over the configuration file.
+func (p Profile) WithEnv() Profile {
+	overlay(&p.UserID, UserIDEnv)
+	overlay(&p.SpreadsheetID, SpreadsheetIDEnv)
+	overlay(&p.Vault, VaultEnv)
+	overlay(&p.SecretsURL, SecretsURLEnv)
+	overlay(&p.QuotesURL, QuotesURLEnv)
+	return p
+}
+
+// WithFlags returns the profile with the values set on flags, which take
+// precedence over both the environment and the configuration file.
+func (p Profile) WithFlags(flags Profile) Profile {
+	override(&p.UserID, flags.UserID)
+	override(&p.SpreadsheetID, flags.SpreadsheetID)
+	override(&p.Vault, flags.Vault)
+	override(&p.SecretsURL, flags.SecretsURL)
+	override(&p.QuotesURL, flags.QuotesURL)
+	return p
+}
+
+// Saves tells if --save should write to the sink.
+func (p Profile) Saves(sink string) bool {
+	if len(p.Sinks) == 0 {
+		return sink == "spreadsheet"
+	}
+	for _, s := range p.Sinks {
+		if s == sink {
+			return true
+		}
+	}
+	return false
+}
+
+// UserSecret returns the name of the secret holding the magnetis username.
+func (p Profile) UserSecret() string {
+	if p.Credentials.User == "" {
+		return secrets.MagnetisUser
+	}
+	return p.Credentials.User
+}
+
+// PasswordSecret returns the name of the secret holding the magnetis password.
+func (p Profile) PasswordSecret() string {
+	if p.Credentials.Password == "" {
+		return secrets.MagnetisPassword
+	}
+	return p.Credentials.Password
+}
+
+func overlay(value *string, names []string) {
+	for _, name := range names {
+		if env, ok := os.LookupEnv(name); ok && env != "" {
+			*value = env
+			return
+		}
+	}
+}
+
+func override(value *string, flag string) {
+	if flag != "" {
+		*value = flag
+	}
+}
+
+func (p Profile) validate() error {
+	for _, sink := range p.Sinks {
+		if !contains(Sinks, sink) {
+			return fmt.Errorf("unknown sink %q, use one of %s", sink, strings.Join(Sinks, ", "))
+		}
+	}
+	for key := range p.Layout {
+		if !contains(LayoutKeys, key) {
+			return fmt.Errorf("unknown layout key %q, use one of %s", key, strings.Join(LayoutKeys, ", "))
+		}
+	}
+	for key := range p.Accounts {
+		if !contains(AccountKeys, key) {
+			return fmt.Errorf("unknown account key %q, use one of %s", key, strings.Join(AccountKeys, ", "))
+		}
+	}
+	return validateTarget(p.Target)
+}
+
+func validateTarget(target map[string]float64) error {
+	var total float64
+	for key, percent := range target {
+		if percent < 0 {
+			return fmt.Errorf("negative target for %q", key)
+		}
+		total += percent
+	}
+	if len(target) > 0 && math.Abs(total-100) > 0.01 {
+		return fmt.Errorf("target percentages add up to %.2f, not 100", total)
+	}
+	return nil
+}
+
+func contains(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,153 @@
+package config
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func load(t *testing.T, yaml string) (*Config, error) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "config.yaml")
+	if err = ioutil.WriteFile(path, []byte(yaml), 0600); err != nil {
+		t.Fatal(err)
+	}
+	return Load(path)
+}
+
+// setenv sets the variables and returns a function restoring them
+func setenv(values map[string]string) func() {
+	saved := make(map[string]*string)
+	for name, value := range values {
+		if old, ok := os.LookupEnv(name); ok {
+			saved[name] = &old
+		} else {
+			saved[name] = nil
+		}
+		os.Setenv(name, value)
+	}
+	return func() {
+		for name, old := range saved {
+			if old == nil {
+				os.Unsetenv(name)
+			} else {
+				os.Setenv(name, *old)
+			}
+		}
+	}
+}
+
+func TestLoadErrors(t *testing.T) {
+	tests := []struct {
+		name string
+		yaml string
+		err  string // Part of the error, empty when valid
+	}{
+		{"valid", "profiles:\n  home:\n    user_id: \"1\"\n    sinks: [spreadsheet, store]\n    target: {fixed_income: 60, stocks: 40}\n", ""},
+		{"unknown key", "profiles:\n  home:\n    userid: \"1\"\n", "field userid not found"},
+		{"unknown top level key", "profile: home\n", "field profile not found"},
+		{"unknown sink", "profiles:\n  home:\n    sinks: [drive]\n", `Profile home: unknown sink "drive"`},
+		{"unknown layout key", "profiles:\n  home:\n    layout: {quotes: Cotacoes}\n", `unknown layout key "quotes"`},
+		{"unknown account key", "profiles:\n  home:\n    accounts: {broker: XP}\n", `unknown account key "broker"`},
+		{"target not 100", "profiles:\n  home:\n    target: {fixed_income: 60, stocks: 30}\n", "add up to 90.00"},
+		{"negative target", "risk_profiles:\n  3: {fixed_income: 110, stocks: -10}\n", `Risk profile 3: negative target for "stocks"`},
+		{"bad holiday", "daemon:\n  holidays: [\"2024-02-30\"]\n", `Daemon holiday "2024-02-30" must be YYYY-MM-DD`},
+		{"bad holiday format", "daemon:\n  holidays: [\"13/02/2024\"]\n", "must be YYYY-MM-DD"},
+	}
+	for _, tt := range tests {
+		_, err := load(t, tt.yaml)
+		if tt.err == "" && err != nil {
+			t.Errorf("%s: Load error = %v", tt.name, err)
+		}
+		if tt.err != "" && (err == nil || !strings.Contains(err.Error(), tt.err)) {
+			t.Errorf("%s: Load error = %v, want %q", tt.name, err, tt.err)
+		}
+	}
+
+	c, err := Load(filepath.Join(os.TempDir(), "missing-magnetis-config.yaml"))
+	if err != nil || len(c.Profiles) != 0 {
+		t.Errorf("Load of a missing file = %+v, %v, want an empty config", c, err)
+	}
+}
+
+func TestProfile(t *testing.T) {
+	c, err := load(t, "default_profile: home\nprofiles:\n  home:\n    user_id: \"1\"\n  work:\n    user_id: \"2\"\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	tests := []struct {
+		name, env, userID, err string
+	}{
+		{"", "", "1", ""},
+		{"", "work", "2", ""},
+		{"home", "work", "1", ""},
+		{"other", "", "", `Unknown profile "other", available: home, work`},
+	}
+	for _, tt := range tests {
+		restore := setenv(map[string]string{ProfileEnv: tt.env})
+		p, err := c.Profile(tt.name)
+		restore()
+		if p.UserID != tt.userID || (err == nil) != (tt.err == "") || (err != nil && err.Error() != tt.err) {
+			t.Errorf("Profile(%q) with %s=%q = %q, %v, want %q, %q", tt.name, ProfileEnv, tt.env, p.UserID, err, tt.userID, tt.err)
+		}
+	}
+}
+
+func TestPrecedence(t *testing.T) {
+	profile := Profile{UserID: "profile", SpreadsheetID: "profile-sheet", QuotesURL: "http://profile"}
+	tests := []struct {
+		name  string
+		env   map[string]string
+		flags Profile
+		want  Profile
+	}{
+		{"profile", map[string]string{"MAGNETIS_USER_ID": "", "GOOGLE_SPREADSHEET_ID": "", "SPREADSHEET_ID": "", "QUOTES_URL": ""}, Profile{},
+			Profile{UserID: "profile", SpreadsheetID: "profile-sheet", QuotesURL: "http://profile"}},
+		{"env over profile", map[string]string{"MAGNETIS_USER_ID": "env", "GOOGLE_SPREADSHEET_ID": "", "SPREADSHEET_ID": "env-sheet", "QUOTES_URL": ""}, Profile{},
+			Profile{UserID: "env", SpreadsheetID: "env-sheet", QuotesURL: "http://profile"}},
+		{"first env name wins", map[string]string{"MAGNETIS_USER_ID": "", "GOOGLE_SPREADSHEET_ID": "google-sheet", "SPREADSHEET_ID": "env-sheet", "QUOTES_URL": ""}, Profile{},
+			Profile{UserID: "profile", SpreadsheetID: "google-sheet", QuotesURL: "http://profile"}},
+		{"flags over env", map[string]string{"MAGNETIS_USER_ID": "env", "GOOGLE_SPREADSHEET_ID": "", "SPREADSHEET_ID": "", "QUOTES_URL": "http://env"}, Profile{UserID: "flag", QuotesURL: "http://flag"},
+			Profile{UserID: "flag", SpreadsheetID: "profile-sheet", QuotesURL: "http://flag"}},
+	}
+	for _, tt := range tests {
+		restore := setenv(tt.env)
+		got := profile.WithEnv().WithFlags(tt.flags)
+		restore()
+		if got.UserID != tt.want.UserID || got.SpreadsheetID != tt.want.SpreadsheetID || got.QuotesURL != tt.want.QuotesURL {
+			t.Errorf("%s: got user %q sheet %q quotes %q, want %q %q %q", tt.name,
+				got.UserID, got.SpreadsheetID, got.QuotesURL, tt.want.UserID, tt.want.SpreadsheetID, tt.want.QuotesURL)
+		}
+	}
+}
+
+func TestDefaults(t *testing.T) {
+	var p Profile
+	if !p.Saves("spreadsheet") || p.Saves("store") {
+		t.Errorf("a profile without sinks should only save to the spreadsheet")
+	}
+	p.Sinks = []string{"store"}
+	if p.Saves("spreadsheet") || !p.Saves("store") {
+		t.Errorf("Saves ignores the configured sinks %v", p.Sinks)
+	}
+	if p.UserSecret() != "MAGNETIS_USER" || p.PasswordSecret() != "MAGNETIS_PASS" {
+		t.Errorf("default secrets %s and %s", p.UserSecret(), p.PasswordSecret())
+	}
+	p.Credentials = Credentials{User: "HOME_USER", Password: "HOME_PASS"}
+	if p.UserSecret() != "HOME_USER" || p.PasswordSecret() != "HOME_PASS" {
+		t.Errorf("configured secrets %s and %s", p.UserSecret(), p.PasswordSecret())
+	}
+
+	restore := setenv(map[string]string{PathEnv: "/etc/magnetis.yaml"})
+	path, err := DefaultPath()
+	restore()
+	if err != nil || path != "/etc/magnetis.yaml" {
+		t.Errorf("DefaultPath = %s, %v, want %s", path, err, "/etc/magnetis.yaml")
+	}
+}
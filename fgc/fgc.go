@@ -0,0 +1,110 @@
+// Package fgc checks the exposure of the assets held on magnetis to each
+// issuer against the coverage of the Fundo Garantidor de Créditos.
+package fgc
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alfredosegundo/magnetis-crawler/magnetis"
+)
+
+// Default FGC limits, per issuer and for all issuers together
+const (
+	DefaultIssuerLimit = 250000.0
+	DefaultGlobalLimit = 1000000.0
+)
+
+// Limits holds the coverage limits used by Check
+type Limits struct {
+	Issuer float64
+	Global float64
+}
+
+// DefaultLimits are the limits set by the FGC regulation
+var DefaultLimits = Limits{Issuer: DefaultIssuerLimit, Global: DefaultGlobalLimit}
+
+// eligible lists the instruments covered by FGC
+var eligible = []string{"CDB", "RDB", "LCI", "LCA", "LC", "LH", "LIG", "DPGE", "POUPANÇA", "POUPANCA"}
+
+// Eligible tells if an instrument type is covered by FGC.
+func Eligible(instrumentType string) bool {
+	upper := strings.ToUpper(strings.TrimSpace(instrumentType))
+	for _, name := range eligible {
+		if upper == name || strings.HasPrefix(upper, name+" ") || strings.HasPrefix(upper, name+"-") {
+			return true
+		}
+	}
+	return false
+}
+
+// An Exposure holds the amount, including accrued yield, held on an issuer.
+type Exposure struct {
+	Issuer       string
+	Covered      float64            // Amount on FGC eligible instruments
+	Uncovered    float64            // Amount on instruments without FGC
+	ByInstrument map[string]float64 // Amount by instrument type
+}
+
+func (e Exposure) String() string {
+	return fmt.Sprintf("%s\t%.2f\t%.2f", e.Issuer, e.Covered, e.Uncovered)
+}
+
+// A Warning tells that an exposure exceeds a limit.
+type Warning struct {
+	Issuer string // Empty for the global limit
+	Amount float64
+	Limit  float64
+}
+
+func (w Warning) String() string {
+	if w.Issuer == "" {
+		return fmt.Sprintf("total covered exposure %.2f exceeds the global FGC limit of %.2f", w.Amount, w.Limit)
+	}
+	return fmt.Sprintf("%s: covered exposure %.2f exceeds the FGC limit of %.2f by %.2f", w.Issuer, w.Amount, w.Limit, w.Amount-w.Limit)
+}
+
+// Check groups the assets by issuer and warns about every issuer whose
+// eligible amount exceeds the issuer limit, and when the eligible amount of
+// all issuers exceeds the global limit.
+func Check(assets []magnetis.Asset, limits Limits) (exposures []Exposure, warnings []Warning) {
+	byIssuer := make(map[string]*Exposure)
+	for _, asset := range assets {
+		issuer := strings.TrimSpace(asset.Issuer)
+		e, ok := byIssuer[strings.ToUpper(issuer)]
+		if !ok {
+			e = &Exposure{Issuer: issuer, ByInstrument: make(map[string]float64)}
+			byIssuer[strings.ToUpper(issuer)] = e
+		}
+		amount, _ := strconv.ParseFloat(strings.TrimSpace(asset.Amount), 64)
+		e.ByInstrument[asset.InstrumentTypeName] += amount
+		if Eligible(asset.InstrumentTypeName) {
+			e.Covered += amount
+		} else {
+			e.Uncovered += amount
+		}
+	}
+
+	var total float64
+	for _, e := range byIssuer {
+		exposures = append(exposures, *e)
+		total += e.Covered
+	}
+	sort.Slice(exposures, func(i, j int) bool {
+		if exposures[i].Covered == exposures[j].Covered {
+			return exposures[i].Issuer < exposures[j].Issuer
+		}
+		return exposures[i].Covered > exposures[j].Covered
+	})
+	for _, e := range exposures {
+		if e.Covered > limits.Issuer {
+			warnings = append(warnings, Warning{Issuer: e.Issuer, Amount: e.Covered, Limit: limits.Issuer})
+		}
+	}
+	if total > limits.Global {
+		warnings = append(warnings, Warning{Amount: total, Limit: limits.Global})
+	}
+	return
+}
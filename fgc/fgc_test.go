@@ -0,0 +1,80 @@
+package fgc
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/alfredosegundo/magnetis-crawler/magnetis"
+)
+
+func asset(issuer, instrument string, amount float64) magnetis.Asset {
+	return magnetis.Asset{Issuer: issuer, InstrumentTypeName: instrument, Amount: fmt.Sprintf("%.2f", amount)}
+}
+
+func TestEligible(t *testing.T) {
+	tests := []struct {
+		instrument string
+		eligible   bool
+	}{
+		{"CDB", true},
+		{" lci ", true},
+		{"LCA-Pré", true},
+		{"Poupança", true},
+		{"LCIX", false},
+		{"Tesouro Selic", false},
+		{"CRI", false},
+	}
+	for _, tt := range tests {
+		if got := Eligible(tt.instrument); got != tt.eligible {
+			t.Errorf("Eligible(%q) = %v, want %v", tt.instrument, got, tt.eligible)
+		}
+	}
+}
+
+func TestCheck(t *testing.T) {
+	tests := []struct {
+		name     string
+		assets   []magnetis.Asset
+		warnings []string
+	}{
+		{"issuer at the limit", []magnetis.Asset{asset("Banco A", "CDB", 250000)}, nil},
+		{"issuer over the limit", []magnetis.Asset{asset("Banco A", "CDB", 200000), asset("banco a ", "LCI", 50000.01)},
+			[]string{"Banco A: covered exposure 250000.01 exceeds the FGC limit of 250000.00 by 0.01"}},
+		{"uncovered not counted", []magnetis.Asset{asset("Banco A", "CDB", 250000), asset("Banco A", "CRI", 100000)}, nil},
+		{"global at the limit", []magnetis.Asset{
+			asset("Banco A", "CDB", 250000), asset("Banco B", "CDB", 250000), asset("Banco C", "LCA", 250000), asset("Banco D", "LCI", 250000),
+		}, nil},
+		{"global over the limit", []magnetis.Asset{
+			asset("Banco A", "CDB", 250000), asset("Banco B", "CDB", 250000), asset("Banco C", "LCA", 250000),
+			asset("Banco D", "LCI", 250000), asset("Banco E", "RDB", 0.01),
+		}, []string{"total covered exposure 1000000.01 exceeds the global FGC limit of 1000000.00"}},
+	}
+	for _, tt := range tests {
+		_, warnings := Check(tt.assets, DefaultLimits)
+		var got []string
+		for _, w := range warnings {
+			got = append(got, w.String())
+		}
+		if strings.Join(got, "\n") != strings.Join(tt.warnings, "\n") {
+			t.Errorf("%s: warnings %q, want %q", tt.name, got, tt.warnings)
+		}
+	}
+}
+
+func TestCheckOrder(t *testing.T) {
+	assets := []magnetis.Asset{
+		asset("Banco C", "CDB", 100), asset("Banco B", "CDB", 100), asset("Banco A", "CRI", 500), asset("Banco D", "LCI", 300),
+	}
+	want := []string{"Banco D", "Banco B", "Banco C", "Banco A"}
+	for i := 0; i < 20; i++ {
+		exposures, _ := Check(assets, DefaultLimits)
+		var got []string
+		for _, e := range exposures {
+			got = append(got, e.Issuer)
+		}
+		if strings.Join(got, ",") != strings.Join(want, ",") {
+			t.Fatalf("Check order = %v, want %v", got, want)
+		}
+	}
+}
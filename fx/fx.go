@@ -0,0 +1,157 @@
+// Package fx converts money values between currencies using a local series
+// of exchange rates, such as the PTAX published by Banco Central do Brasil.
+package fx
+
+import (
+	"bufio"
+	"fmt"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// BRL is the currency every rate is quoted against
+const BRL = "BRL"
+
+// Money is an amount in a currency.
+type Money struct {
+	Amount   float64
+	Currency string
+}
+
+func (m Money) String() string {
+	return fmt.Sprintf("%.2f %s", m.Amount, m.Currency)
+}
+
+// A Rate is the price in BRL of one unit of a currency on a day.
+type Rate struct {
+	Date     time.Time
+	Currency string
+	Buy      float64
+	Sell     float64
+}
+
+// Series holds the rates of each currency sorted by date.
+type Series struct {
+	rates map[string][]Rate
+}
+
+// NewSeries returns a series with the given rates.
+func NewSeries(rates ...Rate) *Series {
+	s := &Series{rates: make(map[string][]Rate)}
+	s.Add(rates...)
+	return s
+}
+
+// Add inserts rates in the series.
+func (s *Series) Add(rates ...Rate) {
+	for _, r := range rates {
+		currency := strings.ToUpper(r.Currency)
+		s.rates[currency] = append(s.rates[currency], r)
+	}
+	for currency := range s.rates {
+		rates := s.rates[currency]
+		sort.SliceStable(rates, func(i, j int) bool { return rates[i].Date.Before(rates[j].Date) })
+	}
+}
+
+// Rate returns the last rate of the currency published on or before date.
+func (s *Series) Rate(currency string, date time.Time) (Rate, error) {
+	currency = strings.ToUpper(currency)
+	if currency == BRL {
+		return Rate{Date: date, Currency: BRL, Buy: 1, Sell: 1}, nil
+	}
+	rates := s.rates[currency]
+	i := sort.Search(len(rates), func(i int) bool { return rates[i].Date.After(date) })
+	if i == 0 {
+		return Rate{}, fmt.Errorf("No %s rate on or before %s", currency, date.Format("2006-01-02"))
+	}
+	return rates[i-1], nil
+}
+
+// Convert converts money to another currency with the selling rates of
+// date, crossing through BRL when neither currency is BRL.
+func (s *Series) Convert(m Money, to string, date time.Time) (Money, error) {
+	if m.Currency == "" {
+		m.Currency = BRL
+	}
+	if strings.EqualFold(m.Currency, to) {
+		return m, nil
+	}
+	from, err := s.Rate(m.Currency, date)
+	if err != nil {
+		return Money{}, err
+	}
+	target, err := s.Rate(to, date)
+	if err != nil {
+		return Money{}, err
+	}
+	return Money{Amount: m.Amount * from.Sell / target.Sell, Currency: strings.ToUpper(to)}, nil
+}
+
+// LoadPTAX reads a rates file, either in the semicolon separated format of
+// the Banco Central PTAX downloads (DDMMYYYY;code;type;currency;buy;sell;...)
+// or as comma separated date (YYYY-MM-DD), currency and rate lines.
+func LoadPTAX(path string) (*Series, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	s := NewSeries()
+	scanner := bufio.NewScanner(f)
+	line := 0
+	for scanner.Scan() {
+		line++
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		r, err := parseRate(text)
+		if err != nil {
+			if line == 1 {
+				continue // header
+			}
+			return nil, fmt.Errorf("Rates file %s line %d: %v", path, line, err)
+		}
+		s.Add(r)
+	}
+	return s, scanner.Err()
+}
+
+func parseRate(text string) (r Rate, err error) {
+	if strings.Contains(text, ";") {
+		fields := strings.Split(text, ";")
+		if len(fields) < 6 {
+			return r, fmt.Errorf("expected at least 6 fields, got %d", len(fields))
+		}
+		if r.Date, err = time.Parse("02012006", strings.TrimSpace(fields[0])); err != nil {
+			return
+		}
+		r.Currency = strings.TrimSpace(fields[3])
+		if r.Buy, err = parseNumber(fields[4]); err != nil {
+			return
+		}
+		r.Sell, err = parseNumber(fields[5])
+		return
+	}
+	fields := strings.Split(text, ",")
+	if len(fields) != 3 {
+		return r, fmt.Errorf("expected 3 fields, got %d", len(fields))
+	}
+	if r.Date, err = time.Parse("2006-01-02", strings.TrimSpace(fields[0])); err != nil {
+		return
+	}
+	r.Currency = strings.TrimSpace(fields[1])
+	if r.Sell, err = parseNumber(fields[2]); err != nil {
+		return
+	}
+	r.Buy = r.Sell
+	return
+}
+
+func parseNumber(value string) (float64, error) {
+	return strconv.ParseFloat(strings.Replace(strings.TrimSpace(value), ",", ".", -1), 64)
+}
@@ -6,7 +6,9 @@ require (
 	github.com/PuerkitoBio/goquery v1.5.0
 	github.com/aws/aws-lambda-go v1.13.3
 	github.com/urfave/cli/v2 v2.0.0
-	golang.org/x/net v0.0.0-20191209160850-c0dbc17a3553
+	golang.org/x/crypto v0.0.0-20220214200702-86341886e292
+	golang.org/x/net v0.0.0-20211112202133-69e39bad7dc2
 	golang.org/x/oauth2 v0.0.0-20191202225959-858c2ad4c8b6
 	google.golang.org/api v0.14.0
+	gopkg.in/yaml.v2 v2.4.0
 )
@@ -0,0 +1,113 @@
+// Package household combines the data of several magnetis accounts.
+package household
+
+import (
+	"fmt"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alfredosegundo/magnetis-crawler/magnetis"
+)
+
+// An Account is a signed in magnetis session of a household member.
+type Account struct {
+	Name   string
+	UserID string
+	Client *magnetis.Client
+}
+
+// Data holds what was crawled from an account.
+type Data struct {
+	Account      string
+	Equities     []magnetis.Equity
+	Applications []magnetis.Application
+}
+
+// Crawl gets the equity curve and the applications of every account, with
+// the applications tagged with the account name.
+func Crawl(accounts []Account) (data []Data, err error) {
+	for _, account := range accounts {
+		curve, err := account.Client.GetEquityCurve(account.UserID)
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", account.Name, err)
+		}
+		applications, err := account.Client.Applications()
+		if err != nil {
+			return nil, fmt.Errorf("%s: %v", account.Name, err)
+		}
+		data = append(data, Data{
+			Account:      account.Name,
+			Equities:     curve.Equities,
+			Applications: Tag(account.Name, applications),
+		})
+	}
+	return
+}
+
+// Tag sets the owner of the applications and gives them IDs of their
+// owner, so the same purchase made by two members keeps two IDs.
+func Tag(owner string, applications []magnetis.Application) []magnetis.Application {
+	tagged := make([]magnetis.Application, len(applications))
+	for i, a := range applications {
+		a.Owner = owner
+		a.ID = ""
+		tagged[i] = a
+	}
+	magnetis.AssignIDs(tagged)
+	return tagged
+}
+
+// MergeCurves sums the equity of the accounts on every date present in any
+// curve. An account missing a date contributes its last known value, or
+// nothing before its first one.
+func MergeCurves(data []Data) []magnetis.Equity {
+	dates := make(map[time.Time]bool)
+	for _, d := range data {
+		for _, e := range d.Equities {
+			dates[day(e.Time)] = true
+		}
+	}
+	sorted := make([]time.Time, 0, len(dates))
+	for date := range dates {
+		sorted = append(sorted, date)
+	}
+	sort.Slice(sorted, func(i, j int) bool { return sorted[i].Before(sorted[j]) })
+
+	totals := make([]float64, len(sorted))
+	for _, d := range data {
+		values := make(map[time.Time]float64)
+		for _, e := range d.Equities {
+			value, _ := strconv.ParseFloat(strings.TrimSpace(e.Value), 64)
+			values[day(e.Time)] = value
+		}
+		var last float64
+		for i, date := range sorted {
+			if value, ok := values[date]; ok {
+				last = value
+			}
+			totals[i] += last
+		}
+	}
+
+	merged := make([]magnetis.Equity, len(sorted))
+	for i, date := range sorted {
+		merged[i] = magnetis.Equity{Time: date, Value: strconv.FormatFloat(totals[i], 'f', 2, 64)}
+	}
+	return merged
+}
+
+// MergeApplications joins the applications of the accounts by date.
+func MergeApplications(data []Data) []magnetis.Application {
+	var merged []magnetis.Application
+	for _, d := range data {
+		merged = append(merged, d.Applications...)
+	}
+	sort.SliceStable(merged, func(i, j int) bool { return merged[i].Date.Before(merged[j].Date) })
+	return merged
+}
+
+func day(t time.Time) time.Time {
+	return time.Date(t.Year(), t.Month(), t.Day(), 0, 0, 0, 0, time.UTC)
+}
@@ -0,0 +1,84 @@
+package household
+
+import (
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alfredosegundo/magnetis-crawler/magnetis"
+)
+
+func march(d int) time.Time {
+	return time.Date(2024, 3, d, 0, 0, 0, 0, time.UTC)
+}
+
+func TestMergeCurves(t *testing.T) {
+	tests := []struct {
+		name string
+		data []Data
+		want []string
+	}{
+		{"same dates", []Data{
+			{Account: "ana", Equities: []magnetis.Equity{{Time: march(1), Value: "100"}, {Time: march(2), Value: "110"}}},
+			{Account: "bruno", Equities: []magnetis.Equity{{Time: march(1), Value: "50"}, {Time: march(2), Value: "55.5"}}},
+		}, []string{"2024-03-01 150.00", "2024-03-02 165.50"}},
+		{"uneven dates", []Data{
+			{Account: "ana", Equities: []magnetis.Equity{{Time: march(1), Value: "100"}, {Time: march(4), Value: "300"}}},
+			{Account: "bruno", Equities: []magnetis.Equity{{Time: march(2), Value: "50"}, {Time: march(3), Value: "60"}}},
+		}, []string{"2024-03-01 100.00", "2024-03-02 150.00", "2024-03-03 160.00", "2024-03-04 360.00"}},
+		{"unsorted with times", []Data{
+			{Account: "ana", Equities: []magnetis.Equity{{Time: march(2).Add(15 * time.Hour), Value: "200"}, {Time: march(1), Value: "100"}}},
+		}, []string{"2024-03-01 100.00", "2024-03-02 200.00"}},
+		{"no data", nil, nil},
+	}
+	for _, tt := range tests {
+		var got []string
+		for _, e := range MergeCurves(tt.data) {
+			got = append(got, e.Time.Format("2006-01-02")+" "+e.Value)
+		}
+		if strings.Join(got, ", ") != strings.Join(tt.want, ", ") {
+			t.Errorf("%s: MergeCurves = %v, want %v", tt.name, got, tt.want)
+		}
+	}
+}
+
+func TestMergeApplications(t *testing.T) {
+	purchase := func(d int, investment string) magnetis.Application {
+		return magnetis.Application{Date: march(d), ApplicationDate: march(d), Type: magnetis.MoneyApplication, Investment: investment, Quantity: 1, Price: 1000, Net: 1000}
+	}
+	tests := []struct {
+		name   string
+		data   []Data
+		want   []string // Owner and investment, in order
+		unique int      // Distinct IDs
+	}{
+		{"ordered by date", []Data{
+			{Account: "ana", Applications: Tag("ana", []magnetis.Application{purchase(1, "CDB"), purchase(3, "LCI")})},
+			{Account: "bruno", Applications: Tag("bruno", []magnetis.Application{purchase(2, "LCA")})},
+		}, []string{"ana CDB", "bruno LCA", "ana LCI"}, 3},
+		{"same day keeps account order", []Data{
+			{Account: "ana", Applications: Tag("ana", []magnetis.Application{purchase(1, "CDB")})},
+			{Account: "bruno", Applications: Tag("bruno", []magnetis.Application{purchase(1, "LCA")})},
+		}, []string{"ana CDB", "bruno LCA"}, 2},
+		{"same purchase by two owners", []Data{
+			{Account: "ana", Applications: Tag("ana", []magnetis.Application{purchase(1, "CDB")})},
+			{Account: "bruno", Applications: Tag("bruno", []magnetis.Application{purchase(1, "CDB")})},
+		}, []string{"ana CDB", "bruno CDB"}, 2},
+	}
+	for _, tt := range tests {
+		merged := MergeApplications(tt.data)
+		var got []string
+		ids := make(map[string]bool)
+		for _, a := range merged {
+			got = append(got, a.Owner+" "+a.Investment)
+			ids[a.ID] = true
+		}
+		if strings.Join(got, ", ") != strings.Join(tt.want, ", ") || len(ids) != tt.unique {
+			t.Errorf("%s: MergeApplications = %v with %d IDs, want %v with %d", tt.name, got, len(ids), tt.want, tt.unique)
+		}
+		again, diff, err := magnetis.Merge(merged, MergeApplications(tt.data))
+		if err != nil || len(again) != len(merged) || !diff.Empty() {
+			t.Errorf("%s: merging the same scrape = %d applications, %s, %v", tt.name, len(again), diff, err)
+		}
+	}
+}
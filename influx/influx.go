@@ -0,0 +1,229 @@
+// Package influx exports the equity curve, asset snapshots and quotes in
+// the InfluxDB line protocol, with second precision timestamps.
+package influx
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alfredosegundo/magnetis-crawler/magnetis"
+	"github.com/alfredosegundo/magnetis-crawler/performance"
+	"github.com/alfredosegundo/magnetis-crawler/stocks"
+)
+
+// A Point is a line of the protocol.
+type Point struct {
+	Measurement string
+	Tags        map[string]string
+	Fields      map[string]float64
+	Time        time.Time
+}
+
+// Series returns the series key of the point, the measurement and its tags
+// sorted by key.
+func (p Point) Series() string {
+	var b strings.Builder
+	b.WriteString(measurementEscaper.Replace(p.Measurement))
+	for _, k := range sortedKeys(p.Tags) {
+		if p.Tags[k] == "" {
+			continue
+		}
+		fmt.Fprintf(&b, ",%s=%s", tagEscaper.Replace(k), tagEscaper.Replace(p.Tags[k]))
+	}
+	return b.String()
+}
+
+// Line formats the point, with tags and fields sorted by key.
+func (p Point) Line() string {
+	var b strings.Builder
+	b.WriteString(p.Series())
+	fields := make([]string, 0, len(p.Fields))
+	for k := range p.Fields {
+		fields = append(fields, k)
+	}
+	sort.Strings(fields)
+	for i, k := range fields {
+		sep := ","
+		if i == 0 {
+			sep = " "
+		}
+		fmt.Fprintf(&b, "%s%s=%s", sep, tagEscaper.Replace(k), strconv.FormatFloat(p.Fields[k], 'f', -1, 64))
+	}
+	fmt.Fprintf(&b, " %d", p.Time.Unix())
+	return b.String()
+}
+
+var (
+	measurementEscaper = strings.NewReplacer(",", `\,`, " ", `\ `)
+	tagEscaper         = strings.NewReplacer(",", `\,`, "=", `\=`, " ", `\ `)
+)
+
+func sortedKeys(m map[string]string) []string {
+	keys := make([]string, 0, len(m))
+	for k := range m {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+	return keys
+}
+
+// EquityPoints returns a point for each day of the equity curve with the
+// equity, invested amount and return.
+func EquityPoints(equities []magnetis.Equity, applications []magnetis.Application) (points []Point) {
+	for _, p := range performance.Compute(equities, applications).Points {
+		points = append(points, Point{
+			Measurement: "equity",
+			Fields:      map[string]float64{"value": p.Equity, "invested": p.Invested, "return": p.Return, "return_percent": p.ReturnPercent},
+			Time:        p.Date,
+		})
+	}
+	return
+}
+
+// AssetPoints returns a snapshot of the assets amount at t.
+func AssetPoints(assets []magnetis.Asset, t time.Time) (points []Point) {
+	for _, a := range assets {
+		amount, _ := strconv.ParseFloat(strings.TrimSpace(a.Amount), 64)
+		points = append(points, Point{
+			Measurement: "asset",
+			Tags:        map[string]string{"asset": a.Name, "category": a.CategoryKey, "issuer": a.Issuer, "instrument_type": a.InstrumentTypeName},
+			Fields:      map[string]float64{"amount": amount},
+			Time:        t,
+		})
+	}
+	return
+}
+
+// QuotePoints returns a point for each quote at its time.
+func QuotePoints(quotes []stocks.Quote) (points []Point) {
+	for _, q := range quotes {
+		fields := map[string]float64{"price": q.Price}
+		if q.PreviousClose > 0 {
+			fields["previous_close"] = q.PreviousClose
+		}
+		points = append(points, Point{
+			Measurement: "quote",
+			Tags:        map[string]string{"symbol": q.Symbol, "currency": q.Currency, "source": q.Source},
+			Fields:      fields,
+			Time:        q.Time,
+		})
+	}
+	return
+}
+
+// State holds the last exported timestamp of each series, so the next
+// export only writes newer points.
+type State struct {
+	Path string
+	Last map[string]time.Time // By series key
+}
+
+// LoadState reads the state file, empty when it doesn't exist.
+func LoadState(path string) (*State, error) {
+	s := &State{Path: path, Last: make(map[string]time.Time)}
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if err = json.Unmarshal(b, &s.Last); err != nil {
+		return nil, fmt.Errorf("Failed to read %s: %v", path, err)
+	}
+	return s, nil
+}
+
+// Since returns the points newer than the last exported of their series.
+func (s *State) Since(points []Point) (newer []Point) {
+	for _, p := range points {
+		if p.Time.Unix() > s.Last[p.Series()].Unix() {
+			newer = append(newer, p)
+		}
+	}
+	return
+}
+
+// Advance records the points as exported.
+func (s *State) Advance(points []Point) {
+	for _, p := range points {
+		if key := p.Series(); p.Time.After(s.Last[key]) {
+			s.Last[key] = p.Time
+		}
+	}
+}
+
+// Save writes the state file, replacing it only once fully written.
+func (s *State) Save() error {
+	b, err := json.MarshalIndent(s.Last, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(s.Path), 0700); err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(s.Path+".tmp", b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(s.Path+".tmp", s.Path)
+}
+
+// Write writes the points, one line each.
+func Write(w io.Writer, points []Point) error {
+	for _, p := range points {
+		if _, err := fmt.Fprintln(w, p.Line()); err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// Post sends the points to an InfluxDB write endpoint, e.g.
+// http://localhost:8086/api/v2/write?org=home&bucket=magnetis or
+// http://localhost:8086/write?db=magnetis, adding the second precision
+// parameter. The token is optional.
+func Post(client *http.Client, uri, token string, points []Point) error {
+	u, err := url.Parse(uri)
+	if err != nil {
+		return err
+	}
+	q := u.Query()
+	q.Set("precision", "s")
+	u.RawQuery = q.Encode()
+	var body bytes.Buffer
+	if err = Write(&body, points); err != nil {
+		return err
+	}
+	req, err := http.NewRequest(http.MethodPost, u.String(), &body)
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "text/plain; charset=utf-8")
+	if token != "" {
+		req.Header.Set("Authorization", "Token "+token)
+	}
+	if client == nil {
+		client = http.DefaultClient
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode/100 != 2 {
+		b, _ := ioutil.ReadAll(resp.Body)
+		return fmt.Errorf("http status code: %d\nbody: %s", resp.StatusCode, string(b))
+	}
+	return nil
+}
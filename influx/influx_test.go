@@ -0,0 +1,59 @@
+package influx
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+
+	"github.com/alfredosegundo/magnetis-crawler/stocks"
+)
+
+func TestLine(t *testing.T) {
+	p := Point{
+		Measurement: "asset",
+		Tags:        map[string]string{"issuer": "", "asset": "CDB Banco, A=1"},
+		Fields:      map[string]float64{"amount": 1100.5},
+		Time:        time.Unix(1709251200, 0),
+	}
+	want := `asset,asset=CDB\ Banco\,\ A\=1 amount=1100.5 1709251200`
+	if got := p.Line(); got != want {
+		t.Errorf("Line() = %s, want %s", got, want)
+	}
+}
+
+func TestStateBySeries(t *testing.T) {
+	day := time.Date(2024, 3, 1, 17, 0, 0, 0, time.UTC)
+	dir, err := ioutil.TempDir("", "influx")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "state", "influx.json")
+
+	state, err := LoadState(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// PETR4 was quoted later than VALE3, which must still be exported
+	state.Advance(QuotePoints([]stocks.Quote{{Symbol: "PETR4", Currency: "BRL", Source: "file", Price: 38, Time: day}}))
+	if err = state.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if state, err = LoadState(path); err != nil {
+		t.Fatal(err)
+	}
+	points := QuotePoints([]stocks.Quote{
+		{Symbol: "PETR4", Currency: "BRL", Source: "file", Price: 38, Time: day},
+		{Symbol: "VALE3", Currency: "BRL", Source: "file", Price: 61, Time: day.Add(-time.Hour)},
+		{Symbol: "PETR4", Currency: "BRL", Source: "file", Price: 39, Time: day.Add(time.Hour)},
+	})
+	newer := state.Since(points)
+	if len(newer) != 2 || newer[0].Tags["symbol"] != "VALE3" || newer[1].Fields["price"] != 39 {
+		t.Errorf("Since = %+v, want VALE3 and the later PETR4 quote", newer)
+	}
+	if _, err = os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("Save left the temporary file behind: %v", err)
+	}
+}
@@ -0,0 +1,246 @@
+// Package jobs runs the crawler tasks shared by the lambda function and
+// the daemon: crawling magnetis data and saving it on the spreadsheet.
+package jobs
+
+import (
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/alfredosegundo/magnetis-crawler/allocation"
+	"github.com/alfredosegundo/magnetis-crawler/fgc"
+	"github.com/alfredosegundo/magnetis-crawler/fx"
+	"github.com/alfredosegundo/magnetis-crawler/magnetis"
+	"github.com/alfredosegundo/magnetis-crawler/spreadsheet"
+	"github.com/alfredosegundo/magnetis-crawler/stocks"
+	"github.com/alfredosegundo/magnetis-crawler/store"
+)
+
+// Options configures the jobs
+type Options struct {
+	SpreadsheetID    string
+	UserID           string           // Discovered on sign in when empty
+	Stocks           []string         // Watchlist of the stocks job, the stocks tab when empty
+	QuotesURL        string           // JSON quote API of the stocks job
+	QuotesPriceField string           // Path to the price in the quote API response, price when empty
+	QuotesCurrency   string           // Currency of the quote API prices, BRL when empty
+	DryRun           bool             // Fetch the data without saving it
+	Store            *store.Store     // Local store to save to, optional
+	Client           *magnetis.Client // Signed in session, the magnetis default client when nil
+}
+
+func (o Options) client() *magnetis.Client {
+	if o.Client == nil {
+		return magnetis.DefaultClient()
+	}
+	return o.Client
+}
+
+// Result reports how a job went
+type Result struct {
+	Job      string   `json:"job"`
+	Status   string   `json:"status"`
+	Rows     int      `json:"rows"`
+	Duration string   `json:"duration"`
+	Error    string   `json:"error,omitempty"`
+	Messages []string `json:"messages,omitempty"`
+}
+
+// A Job crawls and saves one kind of data. The client of the options must
+// be signed in; sheet is nil on dry runs and when only the local
+// store is saved.
+type Job func(o Options, sheet *spreadsheet.Service, result *Result) error
+
+var registry = map[string]Job{
+	"curve":        curveJob,
+	"applications": applicationsJob,
+	"assets":       assetsJob,
+	"plan":         planJob,
+	"stocks":       stocksJob,
+	"analytics":    analyticsJob,
+}
+
+// Known tells if there is a job with the name.
+func Known(name string) bool {
+	_, ok := registry[name]
+	return ok
+}
+
+// Names returns the names of the jobs.
+func Names() []string {
+	names := make([]string, 0, len(registry))
+	for name := range registry {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Run runs the named job and reports its status, rows and duration. The
+// run is recorded on the health of the store unless it is a dry run.
+func Run(name string, o Options, sheet *spreadsheet.Service) Result {
+	result := Result{Job: name, Status: "ok"}
+	start := time.Now()
+	job, ok := registry[name]
+	var err error
+	if !ok {
+		err = fmt.Errorf("Unknown job %q", name)
+	} else {
+		err = job(o, sheet, &result)
+	}
+	if err != nil {
+		result.Status = "error"
+		result.Error = err.Error()
+	}
+	duration := time.Since(start)
+	result.Duration = duration.String()
+	if o.Store != nil && !o.DryRun {
+		if err = o.Store.RecordJob(name, duration, err); err != nil {
+			result.Messages = append(result.Messages, fmt.Sprintf("Failed to record the job health: %v", err))
+		}
+	}
+	return result
+}
+
+func curveJob(o Options, sheet *spreadsheet.Service, result *Result) error {
+	curve, err := o.client().GetEquityCurve(o.UserID)
+	if err != nil {
+		return err
+	}
+	result.Rows = len(curve.Equities)
+	if o.DryRun {
+		return nil
+	}
+	return save(o, sheet, func() error {
+		return sheet.UpdateEquityCurve(curve.Equities, o.SpreadsheetID)
+	}, func(st *store.Store) error {
+		return st.SaveEquities(curve.Equities)
+	})
+}
+
+func applicationsJob(o Options, sheet *spreadsheet.Service, result *Result) error {
+	applications, err := o.client().Applications()
+	if err != nil {
+		return err
+	}
+	result.Rows = len(applications)
+	if o.DryRun {
+		return nil
+	}
+	return save(o, sheet, func() error {
+		return sheet.UpdateApplications(applications, o.SpreadsheetID)
+	}, func(st *store.Store) error {
+		diff, err := st.MergeApplications(applications)
+		if err == nil && !diff.Empty() {
+			result.Messages = append(result.Messages, diff.String())
+		}
+		return err
+	})
+}
+
+func assetsJob(o Options, sheet *spreadsheet.Service, result *Result) error {
+	assets, err := o.client().Assets(o.UserID)
+	if err != nil {
+		return err
+	}
+	result.Rows = len(assets)
+	if o.DryRun {
+		return nil
+	}
+	return save(o, sheet, func() error {
+		return sheet.UpdateAssets(assets, o.SpreadsheetID)
+	}, func(st *store.Store) error {
+		return st.SaveAssets(assets)
+	})
+}
+
+func planJob(o Options, sheet *spreadsheet.Service, result *Result) error {
+	plan, err := o.client().GetInvestmentPlan(o.UserID)
+	if err != nil {
+		return err
+	}
+	result.Rows = 1
+	if o.DryRun {
+		return nil
+	}
+	return save(o, sheet, func() error {
+		return sheet.UpdateInvestmentPlan(plan, o.SpreadsheetID)
+	}, func(st *store.Store) error {
+		return st.SavePlan(plan)
+	})
+}
+
+func stocksJob(o Options, sheet *spreadsheet.Service, result *Result) (err error) {
+	codes := o.Stocks
+	if len(codes) == 0 {
+		if o.DryRun {
+			return fmt.Errorf("stocks must be set on dry runs")
+		}
+		if sheet == nil {
+			return fmt.Errorf("stocks must be set when not saving to the spreadsheet")
+		}
+		if codes, err = sheet.GetConfiguredStocks(o.SpreadsheetID); err != nil {
+			return err
+		}
+	}
+	var providers stocks.Fallback
+	if o.QuotesURL != "" {
+		quotes := stocks.HTTP{URLTemplate: o.QuotesURL, PriceField: o.QuotesPriceField, Currency: o.QuotesCurrency}
+		if quotes.PriceField == "" {
+			quotes.PriceField = "price"
+		}
+		if quotes.Currency == "" {
+			quotes.Currency = fx.BRL
+		}
+		providers = append(providers, stocks.NewRateLimited(quotes, 5))
+	}
+	providers = append(providers, stocks.NewRateLimited(stocks.Google{}, 1))
+	quotes, fetchErr := stocks.Fetch(providers, codes, 4)
+	if fetchErr != nil {
+		result.Messages = append(result.Messages, fetchErr.Error())
+	}
+	result.Rows = len(quotes)
+	if len(quotes) == 0 {
+		return fetchErr
+	}
+	if o.DryRun {
+		return nil
+	}
+	return save(o, sheet, func() error {
+		return sheet.UpdateStocks(quotes, o.SpreadsheetID)
+	}, func(st *store.Store) error {
+		return st.SaveQuotes(quotes)
+	})
+}
+
+// analyticsJob reports the category allocation and the FGC exposure
+// warnings of the assets.
+func analyticsJob(o Options, sheet *spreadsheet.Service, result *Result) error {
+	assets, err := o.client().Assets(o.UserID)
+	if err != nil {
+		return err
+	}
+	for _, slice := range allocation.Breakdown(assets, allocation.Category) {
+		result.Messages = append(result.Messages, slice.String())
+	}
+	_, warnings := fgc.Check(assets, fgc.DefaultLimits)
+	for _, warning := range warnings {
+		result.Messages = append(result.Messages, warning.String())
+	}
+	result.Rows = len(assets)
+	return nil
+}
+
+// save writes to the local store, when set, and to the spreadsheet, when
+// signed in.
+func save(o Options, sheet *spreadsheet.Service, toSheet func() error, toStore func(*store.Store) error) error {
+	if o.Store != nil {
+		if err := toStore(o.Store); err != nil {
+			return err
+		}
+	}
+	if sheet == nil {
+		return nil
+	}
+	return toSheet()
+}
@@ -0,0 +1,32 @@
+package jobs
+
+import (
+	"io/ioutil"
+	"os"
+	"testing"
+
+	"github.com/alfredosegundo/magnetis-crawler/store"
+)
+
+func TestRunRecordsHealth(t *testing.T) {
+	dir, err := ioutil.TempDir("", "jobs")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	st, err := store.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	Run("unknown", Options{Store: st, DryRun: true}, nil)
+	if result := Run("unknown", Options{Store: st}, nil); result.Status != "error" {
+		t.Errorf("Run of an unknown job status = %s, want error", result.Status)
+	}
+	health, err := st.Health()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if j := health.Jobs["unknown"]; j.Runs != 1 || j.Failures != 1 || !j.LastSuccess.IsZero() {
+		t.Errorf("health of the unknown job = %+v, want one failed run", j)
+	}
+}
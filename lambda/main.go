@@ -2,44 +2,142 @@ package main
 
 import (
 	"context"
+	"errors"
+	"fmt"
 	"log"
 	"os"
+	"strings"
 
+	"github.com/alfredosegundo/magnetis-crawler/config"
+	"github.com/alfredosegundo/magnetis-crawler/jobs"
 	"github.com/alfredosegundo/magnetis-crawler/magnetis"
+	"github.com/alfredosegundo/magnetis-crawler/secrets"
 	"github.com/alfredosegundo/magnetis-crawler/spreadsheet"
 
 	"github.com/aws/aws-lambda-go/lambda"
 )
 
 // MyEvent is the event dispatched by aws lambda infrastrucute
-// to start the function
+// to start the function. It tells which jobs to run and where to save them;
+// empty fields fall back to the function environment, then to the profile.
 type MyEvent struct {
-	Name string `json:"name"`
+	Jobs          []string `json:"jobs"`    // curve, applications, assets, plan, stocks, analytics; curve when empty
+	Profile       string   `json:"profile"` // Profile of the configuration file, MAGNETIS_PROFILE when empty
+	SpreadsheetID string   `json:"spreadsheet_id"`
+	UserID        string   `json:"user_id"`
+	Stocks        []string `json:"stocks"`     // Watchlist of the stocks job, the Acoes tab when empty
+	QuotesURL     string   `json:"quotes_url"` // JSON quote API of the stocks job
+	DryRun        bool     `json:"dry_run"`    // Fetch the data without saving it
 }
 
+// Result is returned to aws lambda infrastructure as JSON. When jobs fail
+// the handler also returns an error, so the invocation is retried and
+// counted as failed.
+type Result struct {
+	Jobs  []jobs.Result `json:"jobs"`
+	Error string        `json:"error,omitempty"` // Summary of the failed jobs
+}
+
+// Replaced by the tests
+var (
+	signin = magnetis.Signin
+	runJob = jobs.Run
+)
+
 // HandleRequest is the entrypoint of the lambda function
-func HandleRequest(ctx context.Context, name MyEvent) (string, error) {
-	magnetisUserID := os.Getenv("MAGNETIS_USER")
-	magnetisPassword := os.Getenv("MAGNETIS_PASS")
-	userID := os.Getenv("MAGNETIS_USER_ID")
-	spreadsheetID := os.Getenv("SPREADSHEET_ID")
-	err := magnetis.Signin(magnetisUserID, magnetisPassword)
+func HandleRequest(ctx context.Context, event MyEvent) (Result, error) {
+	if len(event.Jobs) == 0 {
+		event.Jobs = []string{"curve"}
+	}
+	profile, err := loadProfile(event.Profile)
+	if err != nil {
+		return Result{}, err
+	}
+	if event.UserID == "" {
+		event.UserID = profile.UserID
+	}
+	if event.SpreadsheetID == "" {
+		event.SpreadsheetID = profile.SpreadsheetID
+	}
+	if event.QuotesURL == "" {
+		event.QuotesURL = profile.QuotesURL
+	}
+	for _, name := range event.Jobs {
+		if !jobs.Known(name) {
+			return Result{}, fmt.Errorf("Unknown job %q", name)
+		}
+	}
 
+	secretOptions := secrets.OptionsFromEnv()
+	secretOptions.VaultPath = profile.Vault
+	secretOptions.URL = profile.SecretsURL
+	provider, err := secrets.New(secretOptions)
 	if err != nil {
-		log.Fatal(err)
+		return Result{}, err
 	}
-	spreadsheet.SpreadsheetsSignin()
-	curve, err := magnetis.GetEquityCurve(userID)
+	username, err := secrets.Get(provider, profile.UserSecret())
 	if err != nil {
-		log.Fatal(err)
+		return Result{}, err
 	}
-
-	err = spreadsheet.UpdateEquityCurve(curve.Equities, spreadsheetID)
+	password, err := secrets.Get(provider, profile.PasswordSecret())
 	if err != nil {
-		log.Fatal(err)
+		return Result{}, err
+	}
+	if err = signin(username, password); err != nil {
+		return Result{}, fmt.Errorf("magnetis sign in: %v", err)
+	}
+	var sheet *spreadsheet.Service
+	if !event.DryRun {
+		if !profile.Saves("spreadsheet") {
+			return Result{}, fmt.Errorf("profile %s doesn't save to the spreadsheet, use a dry run", event.Profile)
+		}
+		if sheet, err = spreadsheet.SpreadsheetsSignin(provider); err != nil {
+			return Result{}, fmt.Errorf("spreadsheets sign in: %v", err)
+		}
+		sheet.Layout = spreadsheet.NewLayout(profile.Layout)
+	}
+
+	options := jobs.Options{
+		SpreadsheetID:    event.SpreadsheetID,
+		UserID:           event.UserID,
+		Stocks:           event.Stocks,
+		QuotesURL:        event.QuotesURL,
+		QuotesPriceField: profile.QuotesPriceField,
+		QuotesCurrency:   profile.QuotesCurrency,
+		DryRun:           event.DryRun,
+	}
+	var result Result
+	var failed []string
+	for _, name := range event.Jobs {
+		jobResult := runJob(name, options, sheet)
+		if jobResult.Status != "ok" {
+			failed = append(failed, fmt.Sprintf("%s: %s", name, jobResult.Error))
+		}
+		log.Printf("job %s: %s in %s", name, jobResult.Status, jobResult.Duration)
+		result.Jobs = append(result.Jobs, jobResult)
+	}
+	if len(failed) > 0 {
+		result.Error = fmt.Sprintf("%d of %d jobs failed: %s", len(failed), len(event.Jobs), strings.Join(failed, "; "))
+		log.Print(result.Error)
+		return result, errors.New(result.Error)
 	}
+	return result, nil
+}
 
-	return "done", nil
+// loadProfile reads the profile from the configuration file at
+// MAGNETIS_CONFIG, overridden by the environment variables.
+func loadProfile(name string) (config.Profile, error) {
+	var profile config.Profile
+	if path, ok := os.LookupEnv(config.PathEnv); ok {
+		cfg, err := config.Load(path)
+		if err != nil {
+			return profile, err
+		}
+		if profile, err = cfg.Profile(name); err != nil {
+			return profile, err
+		}
+	}
+	return profile.WithEnv(), nil
 }
 
 func main() {
@@ -0,0 +1,48 @@
+package main
+
+import (
+	"context"
+	"os"
+	"testing"
+
+	"github.com/alfredosegundo/magnetis-crawler/jobs"
+	"github.com/alfredosegundo/magnetis-crawler/secrets"
+	"github.com/alfredosegundo/magnetis-crawler/spreadsheet"
+)
+
+func TestHandleRequestFailedJob(t *testing.T) {
+	os.Unsetenv("MAGNETIS_CONFIG")
+	os.Setenv(secrets.MagnetisUser, "user@example.com")
+	os.Setenv(secrets.MagnetisPassword, "secret")
+	defer os.Unsetenv(secrets.MagnetisUser)
+	defer os.Unsetenv(secrets.MagnetisPassword)
+	savedSignin, savedRunJob := signin, runJob
+	defer func() { signin, runJob = savedSignin, savedRunJob }()
+	signin = func(username, password string) error { return nil }
+	runJob = func(name string, o jobs.Options, sheet *spreadsheet.Service) jobs.Result {
+		if name == "stocks" {
+			return jobs.Result{Job: name, Status: "error", Error: "quote API down"}
+		}
+		return jobs.Result{Job: name, Status: "ok"}
+	}
+
+	tests := []struct {
+		jobs []string
+		err  string
+	}{
+		{[]string{"curve"}, ""},
+		{[]string{"curve", "stocks"}, "1 of 2 jobs failed: stocks: quote API down"},
+	}
+	for _, tt := range tests {
+		result, err := HandleRequest(context.Background(), MyEvent{Jobs: tt.jobs, DryRun: true})
+		if tt.err == "" && err != nil {
+			t.Errorf("HandleRequest(%v) error = %v, want nil", tt.jobs, err)
+		}
+		if tt.err != "" && (err == nil || err.Error() != tt.err) {
+			t.Errorf("HandleRequest(%v) error = %v, want %s", tt.jobs, err, tt.err)
+		}
+		if len(result.Jobs) != len(tt.jobs) || result.Error != tt.err {
+			t.Errorf("HandleRequest(%v) result = %+v, want the jobs and error %q", tt.jobs, result, tt.err)
+		}
+	}
+}
@@ -0,0 +1,252 @@
+// Package ledger turns the applications into plain-text accounting
+// journals for ledger, hledger and beancount.
+package ledger
+
+import (
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alfredosegundo/magnetis-crawler/magnetis"
+	"github.com/alfredosegundo/magnetis-crawler/stocks"
+)
+
+// Format is the journal syntax
+type Format int
+
+// Journal formats, hledger reads the ledger format
+const (
+	Ledger Format = iota
+	Beancount
+)
+
+// ParseFormat reads ledger, hledger or beancount.
+func ParseFormat(name string) (Format, error) {
+	switch strings.ToLower(name) {
+	case "ledger", "hledger":
+		return Ledger, nil
+	case "beancount":
+		return Beancount, nil
+	}
+	return 0, fmt.Errorf("Unknown journal format %q, use ledger, hledger or beancount", name)
+}
+
+// Accounts names the journal accounts. Each investment gets a sub account
+// of Investments.
+type Accounts struct {
+	Bank            string // Where contributions come from and redemptions go to
+	Investments     string
+	IR              string
+	AdvisoryFees    string
+	TransactionFees string
+	Portfolio       string // Commodity priced with the equity curve, one unit is the whole portfolio
+	Currency        string
+}
+
+// DefaultAccounts are used for the accounts not configured
+var DefaultAccounts = Accounts{
+	Bank:            "Assets:Bank:Checking",
+	Investments:     "Assets:Investments:Magnetis",
+	IR:              "Expenses:Taxes:IR",
+	AdvisoryFees:    "Expenses:Fees:Advisory",
+	TransactionFees: "Expenses:Fees:Transaction",
+	Portfolio:       "MAGNETIS",
+	Currency:        "BRL",
+}
+
+// NewAccounts returns the default accounts with the names given by key:
+// bank, investments, ir, advisory_fees, transaction_fees, portfolio or
+// currency.
+func NewAccounts(names map[string]string) Accounts {
+	a := DefaultAccounts
+	fields := map[string]*string{
+		"bank":             &a.Bank,
+		"investments":      &a.Investments,
+		"ir":               &a.IR,
+		"advisory_fees":    &a.AdvisoryFees,
+		"transaction_fees": &a.TransactionFees,
+		"portfolio":        &a.Portfolio,
+		"currency":         &a.Currency,
+	}
+	for key, name := range names {
+		if field, ok := fields[key]; ok && name != "" {
+			*field = name
+		}
+	}
+	return a
+}
+
+// A Posting moves an amount in or out of an account.
+type Posting struct {
+	Account string
+	Amount  float64
+}
+
+// A Transaction is a balanced journal entry.
+type Transaction struct {
+	Date      time.Time
+	Narration string
+	Postings  []Posting
+}
+
+// A Price values a commodity on a day.
+type Price struct {
+	Date      time.Time
+	Commodity string
+	Value     float64
+	Currency  string
+}
+
+// Transactions returns a journal entry for each application: purchases
+// and redemptions move money between the bank and the investment, IR
+// withheld on redemptions is a tax expense paid by the investment and fees
+// are expenses paid from the bank.
+func Transactions(applications []magnetis.Application, accounts Accounts) (transactions []Transaction) {
+	for _, a := range applications {
+		date := a.Date
+		if date.IsZero() {
+			date = a.ApplicationDate
+		}
+		investment := strings.TrimSpace(a.Investment)
+		account := accounts.Investments + ":" + AccountName(investment)
+		amount := math.Abs(a.Net)
+		t := Transaction{Date: date}
+		switch a.Type {
+		case magnetis.MoneyApplication:
+			t.Narration = "Aplicação " + investment
+			t.Postings = []Posting{{account, amount}, {accounts.Bank, -amount}}
+		case magnetis.Redemption, magnetis.ExpiredTitle:
+			t.Narration = "Resgate " + investment
+			if a.Type == magnetis.ExpiredTitle {
+				t.Narration = "Vencimento " + investment
+			}
+			t.Postings = []Posting{{accounts.Bank, amount}}
+			if ir := math.Abs(a.IR); ir > 0 {
+				t.Postings = append(t.Postings, Posting{accounts.IR, ir})
+				amount += ir
+			}
+			t.Postings = append(t.Postings, Posting{account, -amount})
+		case magnetis.IRWithdrawal:
+			t.Narration = "IR " + investment
+			if ir := math.Abs(a.IR); ir > 0 {
+				amount = ir
+			}
+			t.Postings = []Posting{{accounts.IR, amount}, {account, -amount}}
+		case magnetis.AdvisoryFee:
+			t.Narration = "Taxa de consultoria"
+			t.Postings = []Posting{{accounts.AdvisoryFees, amount}, {accounts.Bank, -amount}}
+		case magnetis.TransactionFees:
+			t.Narration = "Taxas de transação " + investment
+			t.Postings = []Posting{{accounts.TransactionFees, amount}, {accounts.Bank, -amount}}
+		default:
+			continue
+		}
+		transactions = append(transactions, t)
+	}
+	sort.SliceStable(transactions, func(i, j int) bool { return transactions[i].Date.Before(transactions[j].Date) })
+	return
+}
+
+// EquityPrices prices the portfolio commodity with the equity curve, so the
+// market value of the portfolio can be followed next to its cost.
+func EquityPrices(equities []magnetis.Equity, accounts Accounts) (prices []Price) {
+	for _, e := range equities {
+		value, err := strconv.ParseFloat(strings.TrimSpace(e.Value), 64)
+		if err != nil {
+			continue
+		}
+		prices = append(prices, Price{Date: e.Time, Commodity: accounts.Portfolio, Value: value, Currency: accounts.Currency})
+	}
+	return
+}
+
+// QuotePrices prices each stock with its quote.
+func QuotePrices(quotes []stocks.Quote) (prices []Price) {
+	for _, q := range quotes {
+		prices = append(prices, Price{Date: q.Time, Commodity: q.Symbol, Value: q.Price, Currency: q.Currency})
+	}
+	return
+}
+
+// Write writes the journal. Beancount journals open each account on the
+// day it's first used.
+func Write(w io.Writer, format Format, transactions []Transaction, prices []Price, currency string) error {
+	var b strings.Builder
+	if format == Beancount {
+		opened := make(map[string]bool)
+		for _, t := range transactions {
+			for _, p := range t.Postings {
+				if !opened[p.Account] {
+					opened[p.Account] = true
+					fmt.Fprintf(&b, "%s open %s\n", t.Date.Format("2006-01-02"), p.Account)
+				}
+			}
+		}
+		if len(opened) > 0 {
+			b.WriteString("\n")
+		}
+	}
+	for _, t := range transactions {
+		if format == Beancount {
+			fmt.Fprintf(&b, "%s * \"Magnetis\" %q\n", t.Date.Format("2006-01-02"), t.Narration)
+		} else {
+			fmt.Fprintf(&b, "%s * Magnetis | %s\n", t.Date.Format("2006/01/02"), t.Narration)
+		}
+		for _, p := range t.Postings {
+			fmt.Fprintf(&b, "    %-50s  %12.2f %s\n", p.Account, p.Amount, currency)
+		}
+		b.WriteString("\n")
+	}
+	for _, p := range prices {
+		if p.Currency == "" {
+			p.Currency = currency
+		}
+		if format == Beancount {
+			fmt.Fprintf(&b, "%s price %s %s %s\n", p.Date.Format("2006-01-02"), p.Commodity, formatValue(p.Value), p.Currency)
+		} else {
+			fmt.Fprintf(&b, "P %s %s %s %s\n", p.Date.Format("2006/01/02"), p.Commodity, formatValue(p.Value), p.Currency)
+		}
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+var accents = strings.NewReplacer(
+	"á", "a", "à", "a", "â", "a", "ã", "a", "é", "e", "ê", "e", "í", "i", "ó", "o", "ô", "o", "õ", "o", "ú", "u", "ü", "u", "ç", "c",
+	"Á", "A", "À", "A", "Â", "A", "Ã", "A", "É", "E", "Ê", "E", "Í", "I", "Ó", "O", "Ô", "O", "Õ", "O", "Ú", "U", "Ü", "U", "Ç", "C",
+)
+
+// AccountName turns an investment name into an account name component
+// valid on both ledger and beancount, e.g. "CDB Banco Inter" becomes
+// "CDB-Banco-Inter".
+func AccountName(name string) string {
+	var b strings.Builder
+	dash := false
+	for _, r := range accents.Replace(name) {
+		if r < 128 && (r >= 'A' && r <= 'Z' || r >= 'a' && r <= 'z' || r >= '0' && r <= '9') {
+			if dash && b.Len() > 0 {
+				b.WriteByte('-')
+			}
+			b.WriteRune(r)
+			dash = false
+		} else {
+			dash = true
+		}
+	}
+	s := b.String()
+	if s == "" {
+		return "Unknown"
+	}
+	if s[0] >= 'a' && s[0] <= 'z' {
+		s = strings.ToUpper(s[:1]) + s[1:]
+	}
+	return s
+}
+
+func formatValue(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
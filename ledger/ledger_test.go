@@ -0,0 +1,76 @@
+package ledger
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alfredosegundo/magnetis-crawler/magnetis"
+	"github.com/alfredosegundo/magnetis-crawler/stocks"
+)
+
+func day(month time.Month, d int) time.Time {
+	return time.Date(2024, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+var applications = []magnetis.Application{
+	{ApplicationDate: day(1, 10), Date: day(1, 11), Type: magnetis.MoneyApplication, Investment: "CDB Banco Inter ", Quantity: 1, Price: 1000, Net: 1000},
+	{ApplicationDate: day(1, 15), Type: magnetis.MoneyApplication, Investment: "Fundo Ações", Quantity: 10.5, Price: 20, Net: -210},
+	{Date: day(2, 1), Type: magnetis.AdvisoryFee, Net: 3.5},
+	{Date: day(2, 2), Type: magnetis.TransactionFees, Investment: "Fundo Ações", Net: 1.2},
+	{Date: day(5, 31), Type: magnetis.IRWithdrawal, Investment: "Fundo Ações", IR: -4.1},
+	{Date: day(6, 10), Type: magnetis.Redemption, Investment: "CDB Banco Inter", Quantity: -1, Price: 1100, IR: 22.5, Net: 1077.5},
+	{Date: day(3, 1), Type: magnetis.ExpiredTitle, Investment: "LCI Banco", Quantity: 1, Price: 500, Net: 500},
+}
+
+var prices = append(
+	EquityPrices([]magnetis.Equity{{Time: day(6, 28), Value: "1290.10"}, {Time: day(6, 29), Value: "n/a"}}, DefaultAccounts),
+	QuotePrices([]stocks.Quote{{Symbol: "AAPL", Price: 210.5, Currency: "USD", Time: day(6, 28)}, {Symbol: "PETR4", Price: 38, Time: day(6, 28)}})...,
+)
+
+func TestWrite(t *testing.T) {
+	tests := []struct {
+		format Format
+		golden string
+	}{
+		{Ledger, "journal.ledger"},
+		{Beancount, "journal.beancount"},
+	}
+	for _, tt := range tests {
+		var b strings.Builder
+		if err := Write(&b, tt.format, Transactions(applications, DefaultAccounts), prices, "BRL"); err != nil {
+			t.Fatal(err)
+		}
+		want, err := ioutil.ReadFile(filepath.Join("testdata", tt.golden))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got := b.String(); got != string(want) {
+			t.Errorf("Write %s =\n%s\nwant\n%s", tt.golden, got, want)
+		}
+	}
+}
+
+func TestNewAccounts(t *testing.T) {
+	a := NewAccounts(map[string]string{"bank": "Assets:Nubank", "portfolio": "PORTFOLIO", "ir": ""})
+	if a.Bank != "Assets:Nubank" || a.Portfolio != "PORTFOLIO" || a.IR != DefaultAccounts.IR || a.Currency != "BRL" {
+		t.Errorf("NewAccounts = %+v", a)
+	}
+}
+
+func TestAccountName(t *testing.T) {
+	tests := map[string]string{
+		"CDB Banco Inter":         "CDB-Banco-Inter",
+		" Fundo Ações  (FIA) ":    "Fundo-Acoes-FIA",
+		"tesouro selic 2029":      "Tesouro-selic-2029",
+		"---":                     "Unknown",
+		"Crédito Imobiliário/LCI": "Credito-Imobiliario-LCI",
+	}
+	for name, want := range tests {
+		if got := AccountName(name); got != want {
+			t.Errorf("AccountName(%q) = %q, want %q", name, got, want)
+		}
+	}
+}
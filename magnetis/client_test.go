@@ -0,0 +1,42 @@
+package magnetis
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"testing"
+)
+
+func TestConcurrentSignin(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method == http.MethodPost {
+			http.Redirect(w, r, "/", http.StatusFound)
+			return
+		}
+		w.Write([]byte(`<input name="authenticity_token" value="t"><div data-user-id="42"></div>`))
+	}))
+	defer server.Close()
+	saved := host
+	host = server.URL
+	defer func() { host = saved }()
+
+	c := NewClient()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(2)
+		go func() {
+			defer wg.Done()
+			if err := c.Signin("user@example.com", "secret"); err != nil {
+				t.Error(err)
+			}
+		}()
+		go func() {
+			defer wg.Done()
+			c.user("")
+		}()
+	}
+	wg.Wait()
+	if c.UserID() != "42" {
+		t.Errorf("UserID = %q, want 42", c.UserID())
+	}
+}
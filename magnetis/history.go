@@ -0,0 +1,147 @@
+package magnetis
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"strings"
+	"time"
+)
+
+// IDs returns a deterministic ID for each application, a hash of its
+// dates, type, investment, quantity, net and owner plus its position among
+// the identical applications, so the same history always gets the same IDs.
+func IDs(applications []Application) []string {
+	ids := make([]string, len(applications))
+	seen := make(map[string]int)
+	for i, a := range applications {
+		key := contentKey(a)
+		ids[i] = id(key, seen[key])
+		seen[key]++
+	}
+	return ids
+}
+
+func contentKey(a Application) string {
+	key := fmt.Sprintf("%s|%s|%d|%s|%.6f|%.2f", a.ApplicationDate.Format("2006-01-02"), a.Date.Format("2006-01-02"),
+		a.Type, strings.TrimSpace(a.Investment), a.Quantity, a.Net)
+	if a.Owner != "" {
+		key += "|" + a.Owner
+	}
+	return key
+}
+
+func id(key string, occurrence int) string {
+	sum := sha1.Sum([]byte(fmt.Sprintf("%s|%d", key, occurrence)))
+	return hex.EncodeToString(sum[:10])
+}
+
+// AssignIDs sets the ID of the applications that don't have one yet.
+func AssignIDs(applications []Application) {
+	for i, id := range IDs(applications) {
+		if applications[i].ID == "" {
+			applications[i].ID = id
+		}
+	}
+}
+
+// A Change is a transaction whose price or IR changed between scrapes.
+type Change struct {
+	Before Application
+	After  Application
+}
+
+// A Diff tells how a fresh scrape differs from the stored history.
+type Diff struct {
+	Added   []Application
+	Removed []Application
+	Changed []Change
+}
+
+// Empty tells if nothing changed.
+func (d Diff) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Changed) == 0
+}
+
+func (d Diff) String() string {
+	return fmt.Sprintf("%d added, %d removed, %d changed", len(d.Added), len(d.Removed), len(d.Changed))
+}
+
+// Merge reconciles a fresh scrape against the stored history. The fresh
+// transactions replace the stored ones, keeping the ID of the stored
+// transaction they match so IDs don't shift when transactions are
+// inserted; new transactions get an ID not used yet. Stored transactions
+// older than the whole scrape are kept, as history magnetis no longer
+// lists, while the missing ones within the scrape period are reported as
+// removed. An empty scrape of a stored history is an error, as magnetis
+// never drops the whole history.
+func Merge(stored, fresh []Application) (merged []Application, diff Diff, err error) {
+	if len(fresh) == 0 && len(stored) > 0 {
+		return nil, diff, fmt.Errorf("The scrape has no applications, refusing to replace the %d stored", len(stored))
+	}
+	stored = append([]Application(nil), stored...)
+	fresh = append([]Application(nil), fresh...)
+	AssignIDs(stored)
+
+	unmatched := make(map[string][]int, len(stored)) // Stored indexes by content
+	used := make(map[string]bool, len(stored))
+	for i, a := range stored {
+		key := contentKey(a)
+		unmatched[key] = append(unmatched[key], i)
+		used[a.ID] = true
+	}
+	matched := make([]bool, len(stored))
+	var start time.Time
+	for i := range fresh {
+		a := &fresh[i]
+		if d := settlement(*a); !d.IsZero() && (start.IsZero() || d.Before(start)) {
+			start = d
+		}
+		key := contentKey(*a)
+		if indexes := unmatched[key]; len(indexes) > 0 {
+			before := stored[indexes[0]]
+			unmatched[key] = indexes[1:]
+			matched[indexes[0]] = true
+			a.ID = before.ID
+			if before.Price != a.Price || before.IR != a.IR {
+				diff.Changed = append(diff.Changed, Change{Before: before, After: *a})
+			}
+			continue
+		}
+		a.ID = ""
+		for n := 0; a.ID == ""; n++ {
+			if candidate := id(key, n); !used[candidate] {
+				a.ID = candidate
+				used[candidate] = true
+			}
+		}
+		diff.Added = append(diff.Added, *a)
+	}
+
+	var older []Application
+	for i, a := range stored {
+		if matched[i] {
+			continue
+		}
+		if d := settlement(a); d.Before(start) {
+			older = append(older, a)
+		} else {
+			diff.Removed = append(diff.Removed, a)
+		}
+	}
+	if len(older) == 0 {
+		return fresh, diff, nil
+	}
+	// Keep the older history on the side of the scrape where it belongs
+	if settlement(fresh[0]).After(settlement(fresh[len(fresh)-1])) {
+		return append(fresh, older...), diff, nil
+	}
+	return append(older, fresh...), diff, nil
+}
+
+func settlement(a Application) time.Time {
+	if a.Date.IsZero() {
+		return a.ApplicationDate
+	}
+	return a.Date
+}
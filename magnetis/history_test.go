@@ -0,0 +1,128 @@
+package magnetis
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"strings"
+	"testing"
+	"time"
+)
+
+func application(day int, investment string, net float64) Application {
+	date := time.Date(2024, 1, day, 0, 0, 0, 0, time.UTC)
+	return Application{Date: date, ApplicationDate: date, Type: MoneyApplication, Investment: investment, Quantity: 1, Price: net, Net: net}
+}
+
+func TestIDs(t *testing.T) {
+	a := application(10, "CDB Banco", 1000)
+	b := application(11, "LCI Banco", 500)
+	changed := a
+	changed.Price, changed.IR = 1010, 3
+	spaced := a
+	spaced.Investment = " CDB Banco "
+	occurrence := func(i string) string {
+		sum := sha1.Sum([]byte("2024-01-10|2024-01-10|0|CDB Banco|1.000000|1000.00|" + i))
+		return hex.EncodeToString(sum[:10])
+	}
+	tests := []struct {
+		name         string
+		applications []Application
+		want         []string
+	}{
+		{"single", []Application{a}, []string{occurrence("0")}},
+		{"duplicate rows", []Application{a, b, a}, []string{occurrence("0"), IDs([]Application{b})[0], occurrence("1")}},
+		{"price and IR ignored", []Application{changed}, []string{occurrence("0")}},
+		{"investment trimmed", []Application{spaced}, []string{occurrence("0")}},
+	}
+	for _, tt := range tests {
+		got := IDs(tt.applications)
+		if strings.Join(got, " ") != strings.Join(tt.want, " ") {
+			t.Errorf("%s: IDs = %v, want %v", tt.name, got, tt.want)
+		}
+		if again := IDs(tt.applications); strings.Join(again, " ") != strings.Join(got, " ") {
+			t.Errorf("%s: IDs not deterministic, %v then %v", tt.name, got, again)
+		}
+	}
+}
+
+func TestMerge(t *testing.T) {
+	a1 := application(1, "CDB Banco", 1000)
+	a2 := application(2, "LCI Banco", 500)
+	a3 := application(3, "Tesouro Selic", 300)
+	a2changed := a2
+	a2changed.IR = 2
+	id := func(a Application) string { return IDs([]Application{a})[0] }
+	tests := []struct {
+		name                    string
+		stored, fresh           []Application
+		merged                  []Application
+		added, removed, changed int
+		err                     bool
+	}{
+		{"first scrape", nil, []Application{a2, a1}, []Application{a2, a1}, 2, 0, 0, false},
+		{"added", []Application{a2, a1}, []Application{a3, a2, a1}, []Application{a3, a2, a1}, 1, 0, 0, false},
+		{"removed", []Application{a3, a2, a1}, []Application{a3, a1}, []Application{a3, a1}, 0, 1, 0, false},
+		{"changed", []Application{a2, a1}, []Application{a2changed, a1}, []Application{a2changed, a1}, 0, 0, 1, false},
+		{"older kept after newest first", []Application{a3, a2, a1}, []Application{a3, a2}, []Application{a3, a2, a1}, 0, 0, 0, false},
+		{"older kept before oldest first", []Application{a1, a2, a3}, []Application{a2, a3}, []Application{a1, a2, a3}, 0, 0, 0, false},
+		{"empty scrape", []Application{a2, a1}, nil, nil, 0, 0, 0, true},
+		{"nothing stored nor scraped", nil, nil, nil, 0, 0, 0, false},
+	}
+	for _, tt := range tests {
+		merged, diff, err := Merge(tt.stored, tt.fresh)
+		if (err != nil) != tt.err {
+			t.Errorf("%s: Merge error = %v, want error %v", tt.name, err, tt.err)
+			continue
+		}
+		var got, want []string
+		for _, a := range merged {
+			got = append(got, a.ID)
+		}
+		for _, a := range tt.merged {
+			want = append(want, id(a))
+		}
+		if strings.Join(got, " ") != strings.Join(want, " ") {
+			t.Errorf("%s: merged IDs = %v, want %v", tt.name, got, want)
+		}
+		if len(diff.Added) != tt.added || len(diff.Removed) != tt.removed || len(diff.Changed) != tt.changed {
+			t.Errorf("%s: diff %s, want %d added, %d removed, %d changed", tt.name, diff, tt.added, tt.removed, tt.changed)
+		}
+	}
+}
+
+func TestMergeKeepsIDs(t *testing.T) {
+	a := application(1, "CDB Banco", 1000)
+	b := application(2, "LCI Banco", 500)
+	c := application(3, "Tesouro Selic", 300)
+	tests := []struct {
+		name          string
+		first, second []Application
+	}{
+		{"newer row on top", []Application{b, a}, []Application{c, b, a}},
+		{"row inserted in the middle", []Application{c, a}, []Application{c, b, a}},
+		{"identical row on top", []Application{b, a}, []Application{b, b, a}},
+		{"identical row at the end", []Application{a, b}, []Application{a, b, b}},
+	}
+	for _, tt := range tests {
+		stored, _, err := Merge(nil, tt.first)
+		if err != nil {
+			t.Fatal(err)
+		}
+		merged, diff, err := Merge(stored, tt.second)
+		if err != nil {
+			t.Fatal(err)
+		}
+		ids := make(map[string]bool)
+		for _, a := range merged {
+			ids[a.ID] = true
+		}
+		for _, a := range stored {
+			if !ids[a.ID] {
+				t.Errorf("%s: ID %s of %s changed", tt.name, a.ID, a.Investment)
+			}
+		}
+		if len(ids) != len(tt.second) || len(diff.Added) != 1 || len(diff.Removed) != 0 {
+			t.Errorf("%s: %d distinct IDs and %s, want %d IDs and one added", tt.name, len(ids), diff, len(tt.second))
+		}
+	}
+}
@@ -6,7 +6,9 @@ import (
 	"log"
 	"net/http"
 	"net/http/cookiejar"
+	"regexp"
 	"sort"
+	"sync"
 	"time"
 
 	"net/url"
@@ -22,6 +24,8 @@ import (
 
 var host = "https://magnetis.com.br"
 
+const signinPath = "/users/sign_in"
+
 // An Equity represents the amount of money if all of the assets were liquidated.
 type Equity struct {
 	Time  time.Time // Day when the value was measured
@@ -99,6 +103,7 @@ func (t TransactionType) String() string { return transactionTypes[t] }
 
 // Application represents buy/sell transaction
 type Application struct {
+	ID              string // Stable across scrapes, see IDs
 	Date            time.Time
 	ApplicationDate time.Time
 	Type            TransactionType
@@ -107,6 +112,7 @@ type Application struct {
 	Price           float64
 	IR              float64
 	Net             float64
+	Owner           string // Account holder, only set on household views
 }
 
 func (a Application) String() string {
@@ -118,15 +124,83 @@ func (a Application) Excel() string {
 	return fmt.Sprintf("=DATE(%d,%d,%d)\t%s\t%s\t=%f\t=%f\t=%f\t=%f", a.Date.Year(), a.Date.Month(), a.Date.Day(), a.Investment, a.Type, a.Quantity, a.Price, a.IR, a.Net)
 }
 
-var jar, _ = cookiejar.New(nil)
-var defaultClient = &http.Client{
-	Jar: jar,
+// A Client is a session on magnetis website, with its own cookies so
+// several accounts can be signed in at the same time.
+type Client struct {
+	http *http.Client
+
+	mu     sync.Mutex // Guards userID, set on each sign in
+	userID string
 }
 
+// NewClient returns a client with an empty cookie jar.
+func NewClient() *Client {
+	jar, _ := cookiejar.New(nil)
+	return &Client{http: &http.Client{Jar: jar}}
+}
+
+var defaultClient = NewClient()
+
+// DefaultClient returns the client used by the package functions.
+func DefaultClient() *Client {
+	return defaultClient
+}
+
+// GetEquityCurve gets the equity curve using the default client.
 func GetEquityCurve(userID string) (curve *EquityCurve, err error) {
+	return defaultClient.GetEquityCurve(userID)
+}
+
+// Signin signs in the default client.
+func Signin(username string, password string) (err error) {
+	return defaultClient.Signin(username, password)
+}
+
+// GetInvestmentPlan gets the investment plan using the default client.
+func GetInvestmentPlan(userID string) (plan *InvestmentPlan, err error) {
+	return defaultClient.GetInvestmentPlan(userID)
+}
+
+// Assets gets the assets using the default client.
+func Assets(userID string) (assets []Asset, err error) {
+	return defaultClient.Assets(userID)
+}
+
+// UserID returns the user ID discovered when the default client signed in.
+func UserID() string {
+	return defaultClient.UserID()
+}
+
+// Applications gets the application history using the default client.
+func Applications() (applications []Application, err error) {
+	return defaultClient.Applications()
+}
+
+// UserID returns the user ID discovered on sign in, empty if not found.
+func (c *Client) UserID() string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return c.userID
+}
+
+// user returns the given user ID or the discovered one.
+func (c *Client) user(userID string) (string, error) {
+	if userID != "" {
+		return userID, nil
+	}
+	if userID = c.UserID(); userID != "" {
+		return userID, nil
+	}
+	return "", fmt.Errorf("Unknown user ID, it was not found after signing in")
+}
+
+func (c *Client) GetEquityCurve(userID string) (curve *EquityCurve, err error) {
+	if userID, err = c.user(userID); err != nil {
+		return nil, err
+	}
 	uri := host + "/pricing/api/portfolio/" + userID + "/equity_curve"
 	log.Println(fmt.Sprintf("Equity curve url: %s", uri))
-	resp, err := defaultClient.Get(uri)
+	resp, err := c.http.Get(uri)
 	if err != nil {
 		return nil, err
 	}
@@ -147,18 +221,25 @@ func GetEquityCurve(userID string) (curve *EquityCurve, err error) {
 	}
 	curve = new(EquityCurve)
 	for i := range icurve {
-		equityTime := int64(icurve[i][0].(float64)) / 1000
-		equity := Equity{Time: time.Unix(equityTime, 0).UTC(), Value: icurve[i][1].(string)}
+		if len(icurve[i]) < 2 {
+			return nil, fmt.Errorf("Unexpected equity curve point: %v", icurve[i])
+		}
+		millis, okTime := icurve[i][0].(float64)
+		value, okValue := icurve[i][1].(string)
+		if !okTime || !okValue {
+			return nil, fmt.Errorf("Unexpected equity curve point: %v", icurve[i])
+		}
+		equity := Equity{Time: time.Unix(int64(millis)/1000, 0).UTC(), Value: value}
 		curve.Equities = append(curve.Equities, equity)
 	}
 	sort.Sort(curve)
 	return
 }
 
-func Signin(username string, password string) (err error) {
-	var signin = host + "/users/sign_in"
+func (c *Client) Signin(username string, password string) (err error) {
+	var signin = host + signinPath
 	log.Printf("singing in on: %s", signin)
-	resp, err := defaultClient.Get(signin)
+	resp, err := c.http.Get(signin)
 	if err != nil {
 		return err
 	}
@@ -173,17 +254,87 @@ func Signin(username string, password string) (err error) {
 	}
 	selection := doc.Find("input[name='authenticity_token']")
 	token, _ := selection.First().Attr("value")
-	_, err = defaultClient.PostForm(signin, url.Values{
+	resp, err = c.http.PostForm(signin, url.Values{
 		"authenticity_token": {token},
 		"utf8":               {"✓"},
 		"user[email]":        {username},
 		"user[password]":     {password},
 	})
-	return err
+	if err != nil {
+		return err
+	}
+	body, err := ioutil.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return fmt.Errorf("Failed to read response body: %v", err)
+	}
+	if resp.StatusCode/100 != 2 {
+		return fmt.Errorf("Sign in failed\nhttp status code: %d\nbody: %s", resp.StatusCode, string(body))
+	}
+	// A wrong username or password shows the sign in form again
+	page, err := goquery.NewDocumentFromReader(strings.NewReader(string(body)))
+	if err != nil {
+		return err
+	}
+	if resp.Request.URL.Path == signinPath || page.Find("input[name='user[password]']").Length() > 0 {
+		return fmt.Errorf("Sign in failed, check the username and password")
+	}
+	userID := findUserID(body)
+	if userID == "" {
+		userID = c.discoverUserID()
+	}
+	c.mu.Lock()
+	c.userID = userID
+	c.mu.Unlock()
+	return nil
 }
 
-func GetInvestmentPlan(userID string) (plan *InvestmentPlan, err error) {
-	resp, err := defaultClient.Get(host + "/api/investment_plan/" + userID)
+// userIDPatterns match the portfolio/user ID on the pages shown after
+// signing in: API addresses, data attributes and embedded JSON.
+var userIDPatterns = []*regexp.Regexp{
+	regexp.MustCompile(`/portfolios?/(\d+)`),
+	regexp.MustCompile(`/investment_plan/(\d+)`),
+	regexp.MustCompile(`data-(?:user|portfolio)-id=["'](\d+)["']`),
+	regexp.MustCompile(`"(?:user_id|portfolio_id|userId|portfolioId)"\s*:\s*"?(\d+)`),
+}
+
+func findUserID(page []byte) string {
+	for _, pattern := range userIDPatterns {
+		if match := pattern.FindSubmatch(page); match != nil {
+			return string(match[1])
+		}
+	}
+	return ""
+}
+
+// accountPages are looked up when the user ID is not on the page shown
+// right after signing in.
+var accountPages = []string{"/", "/movimentacoes"}
+
+func (c *Client) discoverUserID() string {
+	for _, page := range accountPages {
+		resp, err := c.http.Get(host + page)
+		if err != nil {
+			continue
+		}
+		body, err := ioutil.ReadAll(resp.Body)
+		resp.Body.Close()
+		if err != nil || resp.StatusCode != http.StatusOK {
+			continue
+		}
+		if userID := findUserID(body); userID != "" {
+			return userID
+		}
+	}
+	log.Println("user ID not found after signing in")
+	return ""
+}
+
+func (c *Client) GetInvestmentPlan(userID string) (plan *InvestmentPlan, err error) {
+	if userID, err = c.user(userID); err != nil {
+		return nil, err
+	}
+	resp, err := c.http.Get(host + "/api/investment_plan/" + userID)
 	if err != nil {
 		return nil, err
 	}
@@ -200,8 +351,11 @@ func GetInvestmentPlan(userID string) (plan *InvestmentPlan, err error) {
 	return
 }
 
-func Assets(userID string) (assets []Asset, err error) {
-	resp, err := defaultClient.Get(host + "/user_portfolio/api/portfolios/" + userID + "/assets")
+func (c *Client) Assets(userID string) (assets []Asset, err error) {
+	if userID, err = c.user(userID); err != nil {
+		return nil, err
+	}
+	resp, err := c.http.Get(host + "/user_portfolio/api/portfolios/" + userID + "/assets")
 	if err != nil {
 		return nil, err
 	}
@@ -217,8 +371,8 @@ func Assets(userID string) (assets []Asset, err error) {
 	return
 }
 
-func Applications() (applications []Application, err error) {
-	res, err := defaultClient.Get(host + "/movimentacoes")
+func (c *Client) Applications() (applications []Application, err error) {
+	res, err := c.http.Get(host + "/movimentacoes")
 	if err != nil {
 		return nil, err
 	}
@@ -275,6 +429,7 @@ func Applications() (applications []Application, err error) {
 
 		applications = append(applications, anTransaction)
 	}
+	AssignIDs(applications)
 	return applications, nil
 }
 
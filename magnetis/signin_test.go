@@ -0,0 +1,122 @@
+package magnetis
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"path/filepath"
+	"testing"
+)
+
+func fixture(t *testing.T, name string) []byte {
+	b, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	return b
+}
+
+func TestFindUserID(t *testing.T) {
+	tests := []struct {
+		page   string
+		userID string
+	}{
+		{"dashboard_api.html", "48213"},
+		{"dashboard_plan.html", "51377"},
+		{"dashboard_data.html", "60021"},
+		{"dashboard_json.html", "70455"},
+		{"dashboard_none.html", ""},
+		{"signin_form.html", ""},
+	}
+	for _, tt := range tests {
+		if got := findUserID(fixture(t, tt.page)); got != tt.userID {
+			t.Errorf("findUserID(%s) = %q, want %q", tt.page, got, tt.userID)
+		}
+	}
+}
+
+func TestSigninDiscoversUserID(t *testing.T) {
+	tests := []struct {
+		afterSignin string // Page shown right after signing in
+		home        string // Page looked up when the ID is not found
+		userID      string
+	}{
+		{"dashboard_api.html", "dashboard_none.html", "48213"},
+		{"dashboard_none.html", "dashboard_data.html", "60021"},
+		{"dashboard_none.html", "dashboard_none.html", ""},
+	}
+	for _, tt := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			switch {
+			case r.URL.Path == "/users/sign_in" && r.Method == http.MethodGet:
+				w.Write(fixture(t, "signin_form.html"))
+			case r.URL.Path == "/users/sign_in":
+				if r.FormValue("authenticity_token") != "csrf-token" || r.FormValue("user[email]") != "user@example.com" {
+					http.Error(w, "bad sign in form", http.StatusUnprocessableEntity)
+					return
+				}
+				http.Redirect(w, r, "/dashboard", http.StatusFound)
+			case r.URL.Path == "/dashboard":
+				w.Write(fixture(t, tt.afterSignin))
+			case r.URL.Path == "/":
+				w.Write(fixture(t, tt.home))
+			default:
+				w.Write(fixture(t, "dashboard_none.html"))
+			}
+		}))
+		saved := host
+		host = server.URL
+		c := NewClient()
+		err := c.Signin("user@example.com", "secret")
+		host = saved
+		server.Close()
+		if err != nil {
+			t.Errorf("Signin: %v", err)
+			continue
+		}
+		if got := c.UserID(); got != tt.userID {
+			t.Errorf("Signin after %s and %s found user ID %q, want %q", tt.afterSignin, tt.home, got, tt.userID)
+		}
+		if _, err = c.user(""); (err == nil) != (tt.userID != "") {
+			t.Errorf("user() error = %v with discovered ID %q", err, tt.userID)
+		}
+	}
+}
+
+func TestSigninFails(t *testing.T) {
+	tests := []struct {
+		name    string
+		handler http.HandlerFunc // Answers the credentials
+	}{
+		{"form shown again", func(w http.ResponseWriter, r *http.Request) {
+			w.Write(fixture(t, "signin_form.html"))
+		}},
+		{"unprocessable entity", func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusUnprocessableEntity)
+			w.Write(fixture(t, "signin_form.html"))
+		}},
+		{"redirected back to the form", func(w http.ResponseWriter, r *http.Request) {
+			http.Redirect(w, r, "/users/sign_in", http.StatusFound)
+		}},
+		{"server error", func(w http.ResponseWriter, r *http.Request) {
+			http.Error(w, "down", http.StatusInternalServerError)
+		}},
+	}
+	for _, tt := range tests {
+		server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.Method == http.MethodPost {
+				tt.handler(w, r)
+				return
+			}
+			w.Write(fixture(t, "signin_form.html"))
+		}))
+		saved := host
+		host = server.URL
+		err := NewClient().Signin("user@example.com", "wrong")
+		host = saved
+		server.Close()
+		if err == nil {
+			t.Errorf("%s: Signin error = nil, want an error", tt.name)
+		}
+	}
+}
@@ -1,19 +1,48 @@
 package main
 
 import (
+	"bufio"
+	"context"
+	"errors"
 	"fmt"
+	"io"
 	"log"
+	"net/http"
 
 	"os"
+	"os/signal"
+	"os/user"
+	"path/filepath"
+	"strings"
+	"syscall"
+	"time"
 
+	"github.com/alfredosegundo/magnetis-crawler/allocation"
+	"github.com/alfredosegundo/magnetis-crawler/calendar"
+	"github.com/alfredosegundo/magnetis-crawler/config"
+	"github.com/alfredosegundo/magnetis-crawler/fgc"
+	"github.com/alfredosegundo/magnetis-crawler/fx"
+	"github.com/alfredosegundo/magnetis-crawler/household"
+	"github.com/alfredosegundo/magnetis-crawler/influx"
+	"github.com/alfredosegundo/magnetis-crawler/jobs"
+	"github.com/alfredosegundo/magnetis-crawler/ledger"
 	"github.com/alfredosegundo/magnetis-crawler/magnetis"
+	"github.com/alfredosegundo/magnetis-crawler/maturity"
+	"github.com/alfredosegundo/magnetis-crawler/metrics"
+	"github.com/alfredosegundo/magnetis-crawler/ofx"
+	"github.com/alfredosegundo/magnetis-crawler/scheduler"
+	"github.com/alfredosegundo/magnetis-crawler/secrets"
+	"github.com/alfredosegundo/magnetis-crawler/server"
 	"github.com/alfredosegundo/magnetis-crawler/spreadsheet"
 	"github.com/alfredosegundo/magnetis-crawler/stocks"
+	"github.com/alfredosegundo/magnetis-crawler/store"
+	"github.com/alfredosegundo/magnetis-crawler/tax"
 
 	"github.com/urfave/cli/v2"
 )
 
 func main() {
+	started := time.Now()
 	var userID string
 	var username string
 	var password string
@@ -21,6 +50,44 @@ func main() {
 	var shouldSave bool
 	var shouldPrint bool
 	var shouldPrintExcel bool
+	var shouldVerify bool
+	var tolerance float64
+	var year int
+	var csvFile string
+	var htmlFile string
+	var cnpjFile string
+	var icsFile string
+	var horizon int
+	var dimension string
+	var targetFile string
+	var contribution float64
+	var issuerLimit float64
+	var globalLimit float64
+	var quotesFile string
+	var quotesURL string
+	var quotesPriceField string
+	var quotesCurrency string
+	var quoteDate string
+	var watchlistFile string
+	var workers int
+	var cacheTTL time.Duration
+	var tradesFile string
+	var fxFile string
+	var vaultPath string
+	var secretsURL string
+	var configPath string
+	var profileName string
+	var profile config.Profile
+	var cfg *config.Config
+	var householdProfiles string
+	var statusFile string
+	var addr string
+	var outputFile string
+	var influxURL string
+	var influxToken string
+	var stateFile string
+	var fullExport bool
+	var noPrices bool
 
 	app := cli.NewApp()
 	app.Name = "Magnetis Crawler"
@@ -31,9 +98,9 @@ func main() {
 		&cli.StringFlag{
 			Name:        "userID",
 			Aliases:     []string{"U"},
-			Usage:       "Your user id on magnetis api",
+			Usage:       "Your user id on magnetis api, discovered on sign in when not set",
 			Destination: &userID,
-			EnvVars:     []string{"MAGNETIS_USER_ID"},
+			EnvVars:     config.UserIDEnv,
 		},
 		&cli.StringFlag{
 			Name:        "username",
@@ -54,8 +121,252 @@ func main() {
 			Aliases:     []string{"sheet"},
 			Usage:       "Your spreadsheet id on google drive",
 			Destination: &spreadsheetID,
-			EnvVars:     []string{"GOOGLE_SPREADSHEET_ID"},
+			EnvVars:     config.SpreadsheetIDEnv,
 		},
+		&cli.StringFlag{
+			Name:        "vault",
+			Usage:       "Encrypted secrets vault, unlocked with MAGNETIS_VAULT_PASSPHRASE",
+			Destination: &vaultPath,
+			EnvVars:     config.VaultEnv,
+		},
+		&cli.StringFlag{
+			Name:        "secrets-url",
+			Usage:       "HTTP endpoint to read secrets from, authenticated with SECRETS_TOKEN",
+			Destination: &secretsURL,
+			EnvVars:     config.SecretsURLEnv,
+		},
+		&cli.StringFlag{
+			Name:        "config",
+			Usage:       "Configuration file with the account profiles (default: ~/.magnetis_crawler/config.yaml)",
+			Destination: &configPath,
+			EnvVars:     []string{config.PathEnv},
+		},
+		&cli.StringFlag{
+			Name:        "profile",
+			Aliases:     []string{"P"},
+			Usage:       "Profile of the configuration file to use",
+			Destination: &profileName,
+			EnvVars:     []string{config.ProfileEnv},
+		},
+	}
+
+	// Flags and environment variables take precedence over the profile
+	app.Before = func(c *cli.Context) error {
+		if configPath == "" {
+			path, err := config.DefaultPath()
+			if err != nil {
+				return err
+			}
+			configPath = path
+		}
+		var err error
+		if cfg, err = config.Load(configPath); err != nil {
+			return err
+		}
+		if profile, err = cfg.Profile(profileName); err != nil {
+			return err
+		}
+		profile = profile.WithEnv().WithFlags(config.Profile{UserID: userID, SpreadsheetID: spreadsheetID, Vault: vaultPath, SecretsURL: secretsURL})
+		userID, spreadsheetID, vaultPath, secretsURL = profile.UserID, profile.SpreadsheetID, profile.Vault, profile.SecretsURL
+		return nil
+	}
+
+	secretsProvider := func() (secrets.Provider, error) {
+		options := secrets.OptionsFromEnv()
+		options.VaultPath = vaultPath
+		options.URL = secretsURL
+		return secrets.New(options)
+	}
+	// credentials reads the username and password not given as flags
+	credentials := func() error {
+		provider, err := secretsProvider()
+		if err != nil {
+			return err
+		}
+		if username == "" {
+			if username, err = secrets.Get(provider, profile.UserSecret()); err != nil {
+				return err
+			}
+		}
+		if password == "" {
+			if password, err = secrets.Get(provider, profile.PasswordSecret()); err != nil {
+				return err
+			}
+		}
+		return nil
+	}
+	signin := func() error {
+		err := credentials()
+		if err != nil {
+			return err
+		}
+		err = magnetis.Signin(username, password)
+		if profile.Saves("store") {
+			if st, openErr := store.Open(profile.Store); openErr != nil {
+				log.Println(openErr)
+			} else if recordErr := st.RecordSignin(err); recordErr != nil {
+				log.Println(recordErr)
+			}
+		}
+		if err != nil {
+			return err
+		}
+		if userID == "" {
+			if userID = magnetis.UserID(); userID == "" {
+				return fmt.Errorf("the user ID was not found after signing in, set it with --userID")
+			}
+		}
+		return nil
+	}
+	// spreadsheetsSignin signs in once per command, later calls return the
+	// same service
+	var sheetService *spreadsheet.Service
+	spreadsheetsSignin := func() (*spreadsheet.Service, error) {
+		if sheetService != nil {
+			return sheetService, nil
+		}
+		if !profile.Saves("spreadsheet") {
+			return nil, fmt.Errorf("the profile doesn't save to the spreadsheet")
+		}
+		provider, err := secretsProvider()
+		if err != nil {
+			return nil, err
+		}
+		sheet, err := spreadsheet.SpreadsheetsSignin(provider)
+		if err != nil {
+			return nil, err
+		}
+		sheet.Layout = spreadsheet.NewLayout(profile.Layout)
+		sheetService = sheet
+		return sheet, nil
+	}
+
+	// save writes to the sinks of the profile, recording the run of the job
+	// on the store health like the daemon does
+	save := func(job string, toSheet func(*spreadsheet.Service) error, toStore func(*store.Store) error) error {
+		var st *store.Store
+		write := func() error {
+			if profile.Saves("store") {
+				var err error
+				if st, err = store.Open(profile.Store); err != nil {
+					return err
+				}
+				if err = toStore(st); err != nil {
+					return err
+				}
+			}
+			if !profile.Saves("spreadsheet") {
+				return nil
+			}
+			sheet, err := spreadsheetsSignin()
+			if err != nil {
+				return err
+			}
+			return toSheet(sheet)
+		}
+		err := write()
+		if st != nil {
+			if recordErr := st.RecordJob(job, time.Since(started), err); recordErr != nil {
+				log.Println(recordErr)
+			}
+		}
+		return err
+	}
+
+	journalFlags := []cli.Flag{
+		&cli.StringFlag{
+			Name:        "output",
+			Aliases:     []string{"o"},
+			Usage:       "Write the journal to this file instead of the standard output",
+			Destination: &outputFile,
+		},
+		&cli.BoolFlag{
+			Name:        "no-prices",
+			Usage:       "Leave out the price directives of the equity curve and stored quotes",
+			Destination: &noPrices,
+		},
+	}
+	exportJournal := func(c *cli.Context) error {
+		format, err := ledger.ParseFormat(c.Command.Name)
+		if err != nil {
+			return err
+		}
+		if err = signin(); err != nil {
+			return err
+		}
+		applications, err := magnetis.Applications()
+		if err != nil {
+			return err
+		}
+		accounts := ledger.NewAccounts(profile.Accounts)
+		transactions := ledger.Transactions(applications, accounts)
+		var prices []ledger.Price
+		if !noPrices {
+			curve, err := magnetis.GetEquityCurve(userID)
+			if err != nil {
+				return err
+			}
+			prices = ledger.EquityPrices(curve.Equities, accounts)
+			if profile.Saves("store") {
+				st, err := store.Open(profile.Store)
+				if err != nil {
+					return err
+				}
+				quotes, err := st.Quotes()
+				if err != nil {
+					return err
+				}
+				prices = append(prices, ledger.QuotePrices(quotes)...)
+			}
+		}
+		write := func(w io.Writer) error {
+			return ledger.Write(w, format, transactions, prices, accounts.Currency)
+		}
+		if outputFile == "" {
+			return write(os.Stdout)
+		}
+		return writeFile(outputFile, write)
+	}
+
+	statementFlags := []cli.Flag{
+		&cli.StringFlag{
+			Name:        "output",
+			Aliases:     []string{"o"},
+			Usage:       "Write the statement to this file instead of the standard output",
+			Destination: &outputFile,
+		},
+	}
+	exportStatement := func(c *cli.Context) error {
+		if err := signin(); err != nil {
+			return err
+		}
+		applications, err := magnetis.Applications()
+		if err != nil {
+			return err
+		}
+		// The stored history keeps the transaction IDs across scrapes
+		if profile.Saves("store") {
+			st, err := store.Open(profile.Store)
+			if err != nil {
+				return err
+			}
+			if _, err = st.MergeApplications(applications); err != nil {
+				return err
+			}
+			if applications, err = st.Applications(); err != nil {
+				return err
+			}
+		}
+		write := func(w io.Writer) error {
+			if c.Command.Name == "qif" {
+				return ofx.WriteQIF(w, applications)
+			}
+			return ofx.Write(w, userID, applications, time.Now())
+		}
+		if outputFile == "" {
+			return write(os.Stdout)
+		}
+		return writeFile(outputFile, write)
 	}
 
 	app.Commands = []*cli.Command{
@@ -82,23 +393,131 @@ func main() {
 					Usage:       "Print on the console as tab separated execel formated values",
 					Destination: &shouldPrintExcel,
 				},
+				&cli.StringFlag{
+					Name:        "quotes-file",
+					Usage:       "CSV file with symbol,price[,currency,time] lines to read quotes from",
+					Destination: &quotesFile,
+				},
+				&cli.StringFlag{
+					Name:        "quotes-url",
+					Usage:       "JSON quote API address, with {symbol} in place of the stock code",
+					Destination: &quotesURL,
+					EnvVars:     config.QuotesURLEnv,
+				},
+				&cli.StringFlag{
+					Name:        "quotes-price-field",
+					Usage:       "Dot separated path to the price in the quote API response (default: price)",
+					Destination: &quotesPriceField,
+				},
+				&cli.StringFlag{
+					Name:        "quotes-currency",
+					Usage:       "Currency of the prices of the quote API (default: BRL)",
+					Destination: &quotesCurrency,
+				},
+				&cli.StringFlag{
+					Name:        "watchlist",
+					Aliases:     []string{"w"},
+					Usage:       "File with one stock code per line, instead of the Acoes tab of the spreadsheet",
+					Destination: &watchlistFile,
+				},
+				&cli.StringSliceFlag{
+					Name:  "import",
+					Usage: "Import a B3 COTAHIST file, plain or zipped, into the local price store",
+				},
+				&cli.IntFlag{
+					Name:        "workers",
+					Usage:       "Number of quotes fetched at the same time",
+					Value:       4,
+					Destination: &workers,
+				},
+				&cli.DurationFlag{
+					Name:        "cache-ttl",
+					Usage:       "Reuse quotes fetched within this duration, 0 disables the cache",
+					Value:       time.Minute,
+					Destination: &cacheTTL,
+				},
+				&cli.StringFlag{
+					Name:        "date",
+					Aliases:     []string{"d"},
+					Usage:       "Value stocks on this past date (YYYY-MM-DD) using the local price store",
+					Destination: &quoteDate,
+				},
 			},
 			Action: func(c *cli.Context) error {
+				var provider stocks.QuoteProvider
+				cotahistFiles := c.StringSlice("import")
+				if len(cotahistFiles) > 0 || quoteDate != "" {
+					storePath, err := stocks.DefaultPriceStorePath()
+					if err != nil {
+						return err
+					}
+					priceStore, err := stocks.OpenPriceStore(storePath)
+					if err != nil {
+						return err
+					}
+					for _, file := range cotahistFiles {
+						prices, err := stocks.ReadCOTAHIST(file)
+						if err != nil {
+							return err
+						}
+						priceStore.Add(prices...)
+						log.Printf("imported %d prices from %s", len(prices), file)
+					}
+					if err = priceStore.Save(); err != nil {
+						return err
+					}
+					if quoteDate != "" {
+						date, err := time.Parse("2006-01-02", quoteDate)
+						if err != nil {
+							return err
+						}
+						provider = priceStore.At(date)
+					}
+				}
+				var cache *stocks.Cache
+				if provider == nil {
+					provider = quoteProvider(quotesFile, firstNonEmpty(quotesURL, profile.QuotesURL),
+						firstNonEmpty(quotesPriceField, profile.QuotesPriceField, "price"), firstNonEmpty(quotesCurrency, profile.QuotesCurrency, fx.BRL))
+					if cacheTTL > 0 {
+						cache = stocks.NewCache(cacheTTL, quotesCacheFile())
+						provider = stocks.Cached{Provider: provider, Cache: cache}
+					}
+				}
+				if !shouldSave && !shouldPrint {
+					return nil
+				}
+				var sheet *spreadsheet.Service
+				if (shouldSave && profile.Saves("spreadsheet")) || watchlistFile == "" {
+					var err error
+					if sheet, err = spreadsheetsSignin(); err != nil {
+						return err
+					}
+				}
+				codes, err := watchlist(watchlistFile, sheet, spreadsheetID)
+				if err != nil {
+					return err
+				}
+				quotes, err := stocks.Fetch(provider, codes, workers)
+				if err != nil {
+					log.Println(err)
+				}
+				if cache != nil {
+					if err := cache.Save(); err != nil {
+						log.Println(err)
+					}
+				}
 				if shouldSave {
-					spreadsheet.SpreadsheetsSignin()
-					codes := spreadsheet.GetConfiguredStocks()
-					for _, code := range codes {
-						value := stocks.GetStockValue(code)
-						spreadsheet.UpdateStocks(code, value)
+					if err := save("stocks", func(*spreadsheet.Service) error {
+						return sheet.UpdateStocks(quotes, spreadsheetID)
+					}, func(st *store.Store) error {
+						return st.SaveQuotes(quotes)
+					}); err != nil {
+						return err
 					}
 				}
-
 				if shouldPrint {
-					spreadsheet.SpreadsheetsSignin()
-					codes := spreadsheet.GetConfiguredStocks()
-					for _, code := range codes {
-						value := stocks.GetStockValue(code)
-						fmt.Printf("%s: %s\n", code, value)
+					for _, quote := range quotes {
+						fmt.Println(quote)
 					}
 				}
 				return nil
@@ -128,14 +547,14 @@ func main() {
 				},
 			},
 			Action: func(c *cli.Context) error {
-				err := magnetis.Signin(username, password)
+				err := signin()
 				if err != nil {
-					log.Fatal(err)
+					return err
 				}
 
 				curve, err := magnetis.GetEquityCurve(userID)
 				if err != nil {
-					log.Fatalf("Error retrieving equity curve: %v", err)
+					return fmt.Errorf("Error retrieving equity curve: %v", err)
 				}
 				if shouldPrint {
 					equities := curve.Equities
@@ -150,11 +569,11 @@ func main() {
 					}
 				}
 				if shouldSave {
-					spreadsheet.SpreadsheetsSignin()
-					err = spreadsheet.UpdateEquityCurve(curve.Equities, spreadsheetID)
-					if err != nil {
-						log.Fatal(err)
-					}
+					return save("curve", func(sheet *spreadsheet.Service) error {
+						return sheet.UpdateEquityCurve(curve.Equities, spreadsheetID)
+					}, func(st *store.Store) error {
+						return st.SaveEquities(curve.Equities)
+					})
 				}
 				return nil
 			},
@@ -178,19 +597,19 @@ func main() {
 				},
 			},
 			Action: func(c *cli.Context) error {
-				err := magnetis.Signin(username, password)
+				err := signin()
 				if err != nil {
-					log.Fatal(err)
+					return err
 				}
 				plan, err := magnetis.GetInvestmentPlan(userID)
 				if err != nil {
-					log.Fatal(err)
+					return err
 				}
 				if shouldPrint {
 					fmt.Printf("%#v\n", plan)
 				}
 				if shouldSave {
-					log.Fatal("Not implemented yet")
+					return fmt.Errorf("Not implemented yet")
 				}
 				return nil
 			},
@@ -214,13 +633,13 @@ func main() {
 				},
 			},
 			Action: func(c *cli.Context) error {
-				err := magnetis.Signin(username, password)
+				err := signin()
 				if err != nil {
-					log.Fatal(err)
+					return err
 				}
 				assets, err := magnetis.Assets(userID)
 				if err != nil {
-					log.Fatal(err)
+					return err
 				}
 				if shouldPrint {
 					for i := range assets {
@@ -228,7 +647,7 @@ func main() {
 					}
 				}
 				if shouldSave {
-					log.Fatal("Not implemented yet")
+					return fmt.Errorf("Not implemented yet")
 				}
 				return nil
 			},
@@ -258,13 +677,13 @@ func main() {
 				},
 			},
 			Action: func(c *cli.Context) error {
-				err := magnetis.Signin(username, password)
+				err := signin()
 				if err != nil {
-					log.Fatal(err)
+					return err
 				}
 				applications, err := magnetis.Applications()
 				if err != nil {
-					log.Fatal(err)
+					return err
 				}
 				if shouldPrint {
 					for i := range applications {
@@ -277,15 +696,927 @@ func main() {
 					}
 				}
 				if shouldSave {
-					spreadsheet.SpreadsheetsSignin()
-					err = spreadsheet.UpdateApplications(applications, spreadsheetID)
+					return save("applications", func(sheet *spreadsheet.Service) error {
+						return sheet.UpdateApplications(applications, spreadsheetID)
+					}, func(st *store.Store) error {
+						diff, err := st.MergeApplications(applications)
+						if err == nil && !diff.Empty() {
+							log.Printf("applications: %s", diff)
+						}
+						return err
+					})
+				}
+				return nil
+			},
+		},
+		{
+			Name:    "tax",
+			Aliases: []string{"t"},
+			Usage:   "Estimate taxes on your current assets and verify the IR on your application history",
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:        "print",
+					Aliases:     []string{"p"},
+					Usage:       "Print the taxes due if all assets were liquidated today",
+					Destination: &shouldPrint,
+				},
+				&cli.BoolFlag{
+					Name:        "verify",
+					Aliases:     []string{"v"},
+					Usage:       "Verify the IR withheld on redemptions",
+					Destination: &shouldVerify,
+				},
+				&cli.Float64Flag{
+					Name:        "tolerance",
+					Usage:       "Accepted difference between reported and expected IR",
+					Value:       0.05,
+					Destination: &tolerance,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				err := signin()
+				if err != nil {
+					return err
+				}
+				applications, err := magnetis.Applications()
+				if err != nil {
+					return err
+				}
+				if shouldPrint {
+					assets, err := magnetis.Assets(userID)
+					if err != nil {
+						return err
+					}
+					for _, estimate := range tax.EstimateLiquidation(assets, applications, time.Now()) {
+						fmt.Println(estimate)
+					}
+				}
+				if shouldVerify {
+					for _, discrepancy := range tax.Verify(applications, tolerance) {
+						fmt.Println(discrepancy)
+					}
+				}
+				return nil
+			},
+		},
+		{
+			Name:  "tax-report",
+			Usage: "Build the annual tax report (Declaração de IRPF) from your application history",
+			Flags: []cli.Flag{
+				&cli.IntFlag{
+					Name:        "year",
+					Aliases:     []string{"y"},
+					Usage:       "Calendar year of the report",
+					Value:       time.Now().Year() - 1,
+					Destination: &year,
+				},
+				&cli.StringFlag{
+					Name:        "csv",
+					Usage:       "Write the report as CSV to this file",
+					Destination: &csvFile,
+				},
+				&cli.StringFlag{
+					Name:        "html",
+					Usage:       "Write the report as printable HTML to this file",
+					Destination: &htmlFile,
+				},
+				&cli.StringFlag{
+					Name:        "cnpj",
+					Usage:       "CSV file mapping issuer names to their CNPJ",
+					Destination: &cnpjFile,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				err := signin()
+				if err != nil {
+					return err
+				}
+				applications, err := magnetis.Applications()
+				if err != nil {
+					return err
+				}
+				assets, err := magnetis.Assets(userID)
+				if err != nil {
+					return err
+				}
+				cnpjs := map[string]string{}
+				if cnpjFile != "" {
+					if cnpjs, err = tax.ReadCNPJs(cnpjFile); err != nil {
+						return err
+					}
+				}
+				report := tax.AnnualReport(year, applications, assets, cnpjs)
+				if csvFile == "" && htmlFile == "" {
+					return report.WriteCSV(os.Stdout)
+				}
+				if csvFile != "" {
+					if err = writeFile(csvFile, report.WriteCSV); err != nil {
+						return err
+					}
+				}
+				if htmlFile != "" {
+					if err = writeFile(htmlFile, report.WriteHTML); err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			Name:    "calendar",
+			Aliases: []string{"cal"},
+			Usage:   "List upcoming maturities and liquidity windows of your assets",
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:        "print",
+					Aliases:     []string{"p"},
+					Usage:       "Print on the console",
+					Destination: &shouldPrint,
+				},
+				&cli.StringFlag{
+					Name:        "ics",
+					Usage:       "Write the calendar as an iCalendar file",
+					Destination: &icsFile,
+				},
+				&cli.IntFlag{
+					Name:        "horizon",
+					Usage:       "Flag assets maturing within this number of days",
+					Value:       30,
+					Destination: &horizon,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				err := signin()
+				if err != nil {
+					return err
+				}
+				assets, err := magnetis.Assets(userID)
+				if err != nil {
+					return err
+				}
+				now := time.Now()
+				events := maturity.Schedule(assets, now, time.Duration(horizon)*24*time.Hour)
+				if shouldPrint {
+					for _, month := range maturity.ByMonth(events) {
+						fmt.Println(month.Month.Format("2006-01"))
+						for _, event := range month.Events {
+							fmt.Printf("\t%s\n", event)
+						}
+					}
+				}
+				if icsFile != "" {
+					err = writeFile(icsFile, func(w io.Writer) error { return maturity.WriteICS(w, events, now) })
+					if err != nil {
+						return err
+					}
+				}
+				return nil
+			},
+		},
+		{
+			Name:    "allocation",
+			Aliases: []string{"al"},
+			Usage:   "Break down your assets by category, issuer or instrument type",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:        "by",
+					Usage:       "Group assets by category, issuer or instrument",
+					Value:       "category",
+					Destination: &dimension,
+				},
+				&cli.StringFlag{
+					Name:        "target",
+					Usage:       "JSON file with the target percentage of each group, defaults to the profile target or the risk profile of the plan",
+					Destination: &targetFile,
+				},
+				&cli.Float64Flag{
+					Name:        "contribution",
+					Usage:       "Suggest how to split this contribution to rebalance the portfolio",
+					Destination: &contribution,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				by, err := allocation.ParseDimension(dimension)
+				if err != nil {
+					return err
+				}
+				err = signin()
+				if err != nil {
+					return err
+				}
+				assets, err := magnetis.Assets(userID)
+				if err != nil {
+					return err
+				}
+				slices := allocation.Breakdown(assets, by)
+				for _, slice := range slices {
+					fmt.Println(slice)
+				}
+
+				var target allocation.Target
+				if targetFile != "" {
+					if target, err = allocation.ReadTarget(targetFile); err != nil {
+						return err
+					}
+				} else if by == allocation.Category && len(profile.Target) > 0 {
+					target = allocation.Target(profile.Target)
+				} else if by == allocation.Category && len(cfg.RiskProfiles) > 0 {
+					plan, err := magnetis.GetInvestmentPlan(userID)
+					if err != nil {
+						return err
+					}
+					levelTarget, ok := cfg.RiskProfiles[plan.RiskLevel]
+					if !ok {
+						return fmt.Errorf("No risk profile configured for the plan risk level %d", plan.RiskLevel)
+					}
+					target = allocation.Target(levelTarget)
+				}
+				if target == nil {
+					return nil
+				}
+				fmt.Println()
+				for _, deviation := range allocation.Compare(slices, target) {
+					fmt.Println(deviation)
+				}
+				if contribution > 0 {
+					fmt.Println()
+					for key, amount := range allocation.Rebalance(slices, target, contribution) {
+						fmt.Printf("%s\t%.2f\n", key, amount)
+					}
+				}
+				return nil
+			},
+		},
+		{
+			Name:  "fgc",
+			Usage: "Check your exposure to each issuer against the FGC coverage",
+			Flags: []cli.Flag{
+				&cli.BoolFlag{
+					Name:        "print",
+					Aliases:     []string{"p"},
+					Usage:       "Print the exposure of every issuer",
+					Destination: &shouldPrint,
+				},
+				&cli.Float64Flag{
+					Name:        "limit",
+					Usage:       "Coverage limit per issuer",
+					Value:       fgc.DefaultIssuerLimit,
+					Destination: &issuerLimit,
+				},
+				&cli.Float64Flag{
+					Name:        "global-limit",
+					Usage:       "Coverage limit for all issuers together",
+					Value:       fgc.DefaultGlobalLimit,
+					Destination: &globalLimit,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				err := signin()
+				if err != nil {
+					return err
+				}
+				assets, err := magnetis.Assets(userID)
+				if err != nil {
+					return err
+				}
+				exposures, warnings := fgc.Check(assets, fgc.Limits{Issuer: issuerLimit, Global: globalLimit})
+				if shouldPrint {
+					for _, exposure := range exposures {
+						fmt.Println(exposure)
+					}
+				}
+				for _, warning := range warnings {
+					fmt.Println(warning)
+				}
+				return nil
+			},
+		},
+		{
+			Name:  "positions",
+			Usage: "Track your stock positions, gains and swing trade taxes from a trades file",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:        "trades",
+					Usage:       "CSV file with date, ticker, quantity (negative for sales), price and fees",
+					Required:    true,
+					Destination: &tradesFile,
+				},
+				&cli.StringFlag{
+					Name:        "quotes-file",
+					Usage:       "CSV file with symbol,price[,currency,time] lines to read quotes from",
+					Destination: &quotesFile,
+				},
+				&cli.StringFlag{
+					Name:        "quotes-url",
+					Usage:       "JSON quote API address, with {symbol} in place of the stock code",
+					Destination: &quotesURL,
+					EnvVars:     config.QuotesURLEnv,
+				},
+				&cli.StringFlag{
+					Name:        "quotes-price-field",
+					Usage:       "Dot separated path to the price in the quote API response (default: price)",
+					Destination: &quotesPriceField,
+				},
+				&cli.StringFlag{
+					Name:        "quotes-currency",
+					Usage:       "Currency of the prices of the quote API (default: BRL)",
+					Destination: &quotesCurrency,
+				},
+				&cli.StringFlag{
+					Name:        "fx",
+					Usage:       "PTAX or date,currency,rate CSV file to convert foreign positions to BRL",
+					Destination: &fxFile,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				trades, err := stocks.ReadTrades(tradesFile)
+				if err != nil {
+					return err
+				}
+				positions, months, err := stocks.Track(trades)
+				if err != nil {
+					return err
+				}
+				var symbols []string
+				for _, position := range positions {
+					if position.Quantity > 0 {
+						symbols = append(symbols, position.Symbol)
+					}
+				}
+				quotes, err := stocks.Fetch(quoteProvider(quotesFile, firstNonEmpty(quotesURL, profile.QuotesURL),
+					firstNonEmpty(quotesPriceField, profile.QuotesPriceField, "price"), firstNonEmpty(quotesCurrency, profile.QuotesCurrency, fx.BRL)), symbols, 4)
+				if err != nil {
+					log.Println(err)
+				}
+				rates := fx.NewSeries()
+				if fxFile != "" {
+					if rates, err = fx.LoadPTAX(fxFile); err != nil {
+						return err
+					}
+				}
+				total := fx.Money{Currency: fx.BRL}
+				now := time.Now()
+				valued, err := stocks.Valued(positions, quotes, rates, now)
+				if err != nil {
+					return err
+				}
+				for _, position := range valued {
+					value, err := rates.Convert(position.MarketValue(), fx.BRL, now)
+					if err != nil {
+						log.Println(err)
+						fmt.Println(position)
+						continue
+					}
+					total.Amount += value.Amount
+					fmt.Printf("%s\t%s\n", position, value)
+				}
+				fmt.Printf("Total\t%s\n", total)
+				fmt.Println()
+				for _, month := range tax.SwingTrade(months) {
+					fmt.Println(month)
+				}
+				return nil
+			},
+		},
+		{
+			Name:  "household",
+			Usage: "Combine the equity curves and applications of several accounts",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:        "profiles",
+					Usage:       "Comma separated profiles of the household accounts, all profiles when empty",
+					Destination: &householdProfiles,
+				},
+				&cli.BoolFlag{
+					Name:        "save",
+					Aliases:     []string{"s"},
+					Usage:       "Save the consolidated and per-account tabs on the spreadsheet",
+					Destination: &shouldSave,
+				},
+				&cli.BoolFlag{
+					Name:        "print",
+					Aliases:     []string{"p"},
+					Usage:       "Print the consolidated equity curve",
+					Destination: &shouldPrint,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				names := cfg.Names()
+				if householdProfiles != "" {
+					names = strings.Split(householdProfiles, ",")
+				}
+				if len(names) == 0 {
+					return fmt.Errorf("no profiles configured on %s", configPath)
+				}
+				var accounts []household.Account
+				for _, name := range names {
+					member, err := cfg.Profile(strings.TrimSpace(name))
+					if err != nil {
+						return err
+					}
+					member = member.WithEnv()
+					options := secrets.OptionsFromEnv()
+					options.VaultPath = member.Vault
+					options.URL = member.SecretsURL
+					provider, err := secrets.New(options)
+					if err != nil {
+						return err
+					}
+					user, err := secrets.Get(provider, member.UserSecret())
+					if err != nil {
+						return err
+					}
+					pass, err := secrets.Get(provider, member.PasswordSecret())
+					if err != nil {
+						return err
+					}
+					client := magnetis.NewClient()
+					if err = client.Signin(user, pass); err != nil {
+						return fmt.Errorf("%s: %v", name, err)
+					}
+					accounts = append(accounts, household.Account{Name: strings.TrimSpace(name), UserID: member.UserID, Client: client})
+				}
+				data, err := household.Crawl(accounts)
+				if err != nil {
+					return err
+				}
+				equities := household.MergeCurves(data)
+				applications := household.MergeApplications(data)
+				if shouldPrint {
+					for i := range equities {
+						fmt.Println(equities[i])
+					}
+				}
+				if shouldSave {
+					sheet, err := spreadsheetsSignin()
 					if err != nil {
-						log.Fatal(err)
+						return err
+					}
+					consolidated := sheet.Layout
+					tabs := []string{consolidated.Curve, consolidated.Applications}
+					for _, d := range data {
+						tabs = append(tabs, fmt.Sprintf("%s (%s)", consolidated.Curve, d.Account), fmt.Sprintf("%s (%s)", consolidated.Applications, d.Account))
+					}
+					if err = sheet.EnsureTabs(spreadsheetID, tabs...); err != nil {
+						return err
+					}
+					if err = sheet.UpdateApplications(applications, spreadsheetID); err != nil {
+						return err
 					}
+					if err = sheet.UpdateEquityCurve(equities, spreadsheetID); err != nil {
+						return err
+					}
+					for _, d := range data {
+						sheet.Layout.Curve = fmt.Sprintf("%s (%s)", consolidated.Curve, d.Account)
+						sheet.Layout.Applications = fmt.Sprintf("%s (%s)", consolidated.Applications, d.Account)
+						// The invested tab is filled by hand for the household, sum the account applications instead
+						sheet.Layout.Invested = ""
+						if err = sheet.UpdateApplications(d.Applications, spreadsheetID); err != nil {
+							return err
+						}
+						if err = sheet.UpdateEquityCurve(d.Equities, spreadsheetID); err != nil {
+							return err
+						}
+					}
+					sheet.Layout = consolidated
 				}
 				return nil
 			},
 		},
+		{
+			Name:  "daemon",
+			Usage: "Run the jobs of the configuration file on their schedules until interrupted",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:        "status-file",
+					Usage:       "JSON file with the last run of each job (default: ~/.magnetis_crawler/daemon.json)",
+					Destination: &statusFile,
+				},
+				&cli.StringFlag{
+					Name:        "metrics-addr",
+					Usage:       "Address to serve Prometheus metrics on, disabled when empty",
+					Destination: &addr,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				daemon := cfg.Daemon
+				if len(daemon.Jobs) == 0 {
+					return fmt.Errorf("no daemon jobs configured on %s", configPath)
+				}
+				location := time.Local
+				if daemon.Timezone != "" {
+					var err error
+					if location, err = time.LoadLocation(daemon.Timezone); err != nil {
+						return err
+					}
+				}
+				cal := calendar.New()
+				if daemon.Calendar != "" {
+					if err := cal.Load(daemon.Calendar); err != nil {
+						return err
+					}
+				}
+				for _, day := range daemon.Holidays {
+					holiday, err := time.ParseInLocation("2006-01-02", day, calendar.Location)
+					if err != nil {
+						return fmt.Errorf("daemon holiday %q must be YYYY-MM-DD", day)
+					}
+					cal.Close(holiday, "Feriado")
+				}
+				statusFile = firstNonEmpty(statusFile, daemon.StatusFile, daemonStatusFile())
+				if err := os.MkdirAll(filepath.Dir(statusFile), 0700); err != nil {
+					return err
+				}
+				var sheet *spreadsheet.Service
+				if profile.Saves("spreadsheet") {
+					var err error
+					if sheet, err = spreadsheetsSignin(); err != nil {
+						return err
+					}
+				}
+				var st *store.Store
+				if profile.Saves("store") {
+					var err error
+					if st, err = store.Open(profile.Store); err != nil {
+						return err
+					}
+				}
+				s := &scheduler.Scheduler{
+					Jitter:     daemon.Jitter,
+					Location:   location,
+					StatusPath: statusFile,
+					Calendar:   cal,
+				}
+				if err := credentials(); err != nil {
+					return err
+				}
+				// Each run signs in on its own session, as sessions expire and
+				// jobs may run at the same time
+				for _, j := range daemon.Jobs {
+					if !jobs.Known(j.Name) {
+						return fmt.Errorf("Unknown daemon job %q, use one of %s", j.Name, strings.Join(jobs.Names(), ", "))
+					}
+					schedule, err := scheduler.Parse(j.Schedule)
+					if err != nil {
+						return fmt.Errorf("daemon job %s: %v", j.Name, err)
+					}
+					name := j.Name
+					s.Entries = append(s.Entries, scheduler.Entry{
+						Name:        name,
+						Schedule:    schedule,
+						TradingDays: j.TradingDays,
+						MarketHours: j.MarketHours,
+						Run: func() error {
+							client := magnetis.NewClient()
+							err := client.Signin(username, password)
+							if st != nil {
+								if recordErr := st.RecordSignin(err); recordErr != nil {
+									log.Println(recordErr)
+								}
+							}
+							if err != nil {
+								return fmt.Errorf("magnetis sign in: %v", err)
+							}
+							options := jobs.Options{SpreadsheetID: spreadsheetID, UserID: userID, QuotesURL: profile.QuotesURL,
+								QuotesPriceField: profile.QuotesPriceField, QuotesCurrency: profile.QuotesCurrency, Store: st, Client: client}
+							result := jobs.Run(name, options, sheet)
+							if result.Status != "ok" {
+								return errors.New(result.Error)
+							}
+							return nil
+						},
+					})
+				}
+
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+				signals := make(chan os.Signal, 1)
+				signal.Notify(signals, syscall.SIGINT, syscall.SIGTERM)
+				go func() {
+					sig := <-signals
+					log.Printf("daemon: %s received, waiting for the running jobs", sig)
+					cancel()
+				}()
+				if addr != "" {
+					if st == nil {
+						return fmt.Errorf("--metrics-addr needs the profile to save to the store")
+					}
+					mux := http.NewServeMux()
+					mux.Handle("/metrics", metrics.Handler(st))
+					go func() {
+						log.Printf("daemon: serving metrics on http://%s/metrics", addr)
+						log.Println(http.ListenAndServe(addr, mux))
+					}()
+				}
+				log.Printf("daemon: %d jobs scheduled in %s, status on %s", len(s.Entries), location, statusFile)
+				return s.Run(ctx)
+			},
+		},
+		{
+			Name:  "serve",
+			Usage: "Serve the local store as a JSON API and a web dashboard",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:        "addr",
+					Usage:       "Address to listen on",
+					Value:       "localhost:8080",
+					Destination: &addr,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				st, err := store.Open(profile.Store)
+				if err != nil {
+					return err
+				}
+				mux := server.Handler(st)
+				mux.Handle("/metrics", metrics.Handler(st))
+				log.Printf("serving %s on http://%s", st.Dir, addr)
+				return http.ListenAndServe(addr, mux)
+			},
+		},
+		{
+			Name:  "metrics",
+			Usage: "Serve the local store as Prometheus metrics on /metrics",
+			Flags: []cli.Flag{
+				&cli.StringFlag{
+					Name:        "addr",
+					Usage:       "Address to listen on",
+					Value:       "localhost:9101",
+					Destination: &addr,
+				},
+			},
+			Action: func(c *cli.Context) error {
+				st, err := store.Open(profile.Store)
+				if err != nil {
+					return err
+				}
+				mux := http.NewServeMux()
+				mux.Handle("/metrics", metrics.Handler(st))
+				log.Printf("serving metrics of %s on http://%s/metrics", st.Dir, addr)
+				return http.ListenAndServe(addr, mux)
+			},
+		},
+		{
+			Name:  "export",
+			Usage: "Export your portfolio to other tools",
+			Subcommands: []*cli.Command{
+				{
+					Name:    "ledger",
+					Aliases: []string{"hledger"},
+					Usage:   "Export your applications as a ledger or hledger journal",
+					Flags:   journalFlags,
+					Action:  exportJournal,
+				},
+				{
+					Name:   "beancount",
+					Usage:  "Export your applications as a beancount journal",
+					Flags:  journalFlags,
+					Action: exportJournal,
+				},
+				{
+					Name:   "ofx",
+					Usage:  "Export your applications as an OFX investment statement",
+					Flags:  statementFlags,
+					Action: exportStatement,
+				},
+				{
+					Name:   "qif",
+					Usage:  "Export your applications as a QIF investment account",
+					Flags:  statementFlags,
+					Action: exportStatement,
+				},
+				{
+					Name:  "influx",
+					Usage: "Export the equity curve, asset snapshots and stored quotes as InfluxDB line protocol",
+					Flags: []cli.Flag{
+						&cli.StringFlag{
+							Name:        "output",
+							Aliases:     []string{"o"},
+							Usage:       "Append the lines to this file; without output and url they are printed and the state is kept",
+							Destination: &outputFile,
+						},
+						&cli.StringFlag{
+							Name:        "url",
+							Usage:       "InfluxDB write endpoint, e.g. http://localhost:8086/api/v2/write?org=home&bucket=magnetis",
+							Destination: &influxURL,
+						},
+						&cli.StringFlag{
+							Name:        "token",
+							Usage:       "InfluxDB API token",
+							Destination: &influxToken,
+							EnvVars:     []string{"INFLUX_TOKEN"},
+						},
+						&cli.StringFlag{
+							Name:        "state",
+							Usage:       "File with the last exported timestamps (default: ~/.magnetis_crawler/influx.json)",
+							Destination: &stateFile,
+						},
+						&cli.BoolFlag{
+							Name:        "full",
+							Usage:       "Export all points, not only the ones newer than the last export",
+							Destination: &fullExport,
+						},
+					},
+					Action: func(c *cli.Context) error {
+						if err := signin(); err != nil {
+							return err
+						}
+						curve, err := magnetis.GetEquityCurve(userID)
+						if err != nil {
+							return err
+						}
+						applications, err := magnetis.Applications()
+						if err != nil {
+							return err
+						}
+						assets, err := magnetis.Assets(userID)
+						if err != nil {
+							return err
+						}
+						points := influx.EquityPoints(curve.Equities, applications)
+						points = append(points, influx.AssetPoints(assets, time.Now())...)
+						if profile.Saves("store") {
+							st, err := store.Open(profile.Store)
+							if err != nil {
+								return err
+							}
+							quotes, err := st.Quotes()
+							if err != nil {
+								return err
+							}
+							points = append(points, influx.QuotePoints(quotes)...)
+						}
+
+						if stateFile == "" {
+							stateFile = filepath.Join(filepath.Dir(quotesCacheFile()), "influx.json")
+						}
+						state, err := influx.LoadState(stateFile)
+						if err != nil {
+							return err
+						}
+						if !fullExport {
+							points = state.Since(points)
+						}
+						if outputFile == "" && influxURL == "" {
+							return influx.Write(os.Stdout, points)
+						}
+						// The file is only appended once the points are posted, so a
+						// failed post doesn't leave them in the file to be appended again
+						if influxURL != "" && len(points) > 0 {
+							if err = influx.Post(nil, influxURL, influxToken, points); err != nil {
+								return err
+							}
+						}
+						if outputFile != "" {
+							f, err := os.OpenFile(outputFile, os.O_APPEND|os.O_CREATE|os.O_WRONLY, 0644)
+							if err != nil {
+								return err
+							}
+							defer f.Close()
+							if err = influx.Write(f, points); err != nil {
+								return err
+							}
+						}
+						log.Printf("exported %d points", len(points))
+						state.Advance(points)
+						return state.Save()
+					},
+				},
+			},
+		},
+		{
+			Name:  "vault",
+			Usage: "Manage the secrets of the encrypted vault",
+			Subcommands: []*cli.Command{
+				{
+					Name:      "set",
+					Usage:     "Store a secret, reading its value from the standard input when not given",
+					ArgsUsage: "NAME [VALUE]",
+					Action: func(c *cli.Context) error {
+						vault, err := openVault(vaultPath)
+						if os.IsNotExist(err) {
+							vault, err = secrets.NewVault(vaultPath, os.Getenv("MAGNETIS_VAULT_PASSPHRASE"))
+						}
+						if err != nil {
+							return err
+						}
+						name, value := c.Args().Get(0), c.Args().Get(1)
+						if name == "" {
+							return fmt.Errorf("missing secret name")
+						}
+						if c.Args().Len() < 2 {
+							if value, err = bufio.NewReader(os.Stdin).ReadString('\n'); err != nil && err != io.EOF {
+								return err
+							}
+							value = strings.TrimRight(value, "\r\n")
+						}
+						vault.Set(name, value)
+						return vault.Save()
+					},
+				},
+				{
+					Name:      "delete",
+					Usage:     "Remove a secret",
+					ArgsUsage: "NAME",
+					Action: func(c *cli.Context) error {
+						vault, err := openVault(vaultPath)
+						if err != nil {
+							return err
+						}
+						vault.Delete(c.Args().First())
+						return vault.Save()
+					},
+				},
+				{
+					Name:  "list",
+					Usage: "List the names of the stored secrets",
+					Action: func(c *cli.Context) error {
+						vault, err := openVault(vaultPath)
+						if err != nil {
+							return err
+						}
+						for _, name := range vault.Names() {
+							fmt.Println(name)
+						}
+						return nil
+					},
+				},
+			},
+		},
+	}
+	if err := app.Run(os.Args); err != nil {
+		log.Fatal(err)
+	}
+}
+
+// quoteProvider chains the configured quote providers, falling back to
+// google search results. Remote providers are rate limited.
+func quoteProvider(file string, uri string, priceField string, currency string) stocks.QuoteProvider {
+	var providers stocks.Fallback
+	if file != "" {
+		providers = append(providers, stocks.File{Path: file})
+	}
+	if uri != "" {
+		quotes := stocks.HTTP{URLTemplate: uri, PriceField: priceField, Currency: currency}
+		providers = append(providers, stocks.NewRateLimited(quotes, 5))
+	}
+	return append(providers, stocks.NewRateLimited(stocks.Google{}, 1))
+}
+
+// quotesCacheFile returns the on-disk quotes cache, empty if the home
+// directory can't be found.
+func quotesCacheFile() string {
+	usr, err := user.Current()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(usr.HomeDir, ".magnetis_crawler", "quotes.json")
+}
+
+// daemonStatusFile returns the default daemon status file, empty if the
+// home directory can't be found.
+func daemonStatusFile() string {
+	usr, err := user.Current()
+	if err != nil {
+		return ""
+	}
+	return filepath.Join(usr.HomeDir, ".magnetis_crawler", "daemon.json")
+}
+
+// watchlist reads the stock codes from the file, when given, or from the spreadsheet.
+func watchlist(file string, sheet *spreadsheet.Service, spreadsheetID string) ([]string, error) {
+	if file != "" {
+		return stocks.ReadWatchlist(file)
+	}
+	return sheet.GetConfiguredStocks(spreadsheetID)
+}
+
+// openVault unlocks the vault with MAGNETIS_VAULT_PASSPHRASE.
+func openVault(path string) (*secrets.Vault, error) {
+	if path == "" {
+		return nil, fmt.Errorf("set the vault path with --vault or MAGNETIS_VAULT")
+	}
+	passphrase, ok := os.LookupEnv("MAGNETIS_VAULT_PASSPHRASE")
+	if !ok {
+		return nil, fmt.Errorf("set the vault passphrase on MAGNETIS_VAULT_PASSPHRASE")
+	}
+	return secrets.OpenVault(path, passphrase)
+}
+
+func firstNonEmpty(values ...string) string {
+	for _, v := range values {
+		if v != "" {
+			return v
+		}
+	}
+	return ""
+}
+
+func writeFile(name string, write func(io.Writer) error) error {
+	f, err := os.Create(name)
+	if err != nil {
+		return err
 	}
-	app.Run(os.Args)
+	defer f.Close()
+	return write(f)
 }
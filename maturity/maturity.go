@@ -0,0 +1,149 @@
+// Package maturity builds the calendar of maturities and liquidity windows
+// of the assets held on magnetis.
+package maturity
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+	"time"
+	"unicode/utf8"
+
+	"github.com/alfredosegundo/magnetis-crawler/calendar"
+	"github.com/alfredosegundo/magnetis-crawler/magnetis"
+)
+
+// Kind tells which event happens on an asset
+type Kind int
+
+// Kinds of events on an asset
+const (
+	Maturity Kind = iota
+	Liquidity
+)
+
+var kinds = [...]string{
+	"Maturity",
+	"Liquidity",
+}
+
+func (k Kind) String() string { return kinds[k] }
+
+// An Event is a day when money from an asset becomes available.
+type Event struct {
+	Date  time.Time
+	Kind  Kind
+	Asset magnetis.Asset
+	Due   bool // The event happens within the configured horizon
+}
+
+func (e Event) String() string {
+	due := ""
+	if e.Due {
+		due = "\t!"
+	}
+	return fmt.Sprintf("%s\t%s\t%s\t%s%s", e.Date.Format("2006-01-02"), e.Kind, e.Asset.Name, e.Asset.Amount, due)
+}
+
+// A Month groups the events happening on the same month.
+type Month struct {
+	Month  time.Time // First day of the month
+	Events []Event
+}
+
+var dateLayouts = []string{"2006-01-02", time.RFC3339, "02/01/2006"}
+
+func parseDate(value string) (date time.Time, err error) {
+	value = strings.TrimSpace(value)
+	for _, layout := range dateLayouts {
+		if date, err = time.Parse(layout, value); err == nil {
+			return
+		}
+	}
+	return time.Time{}, fmt.Errorf("Unknown date format: %q", value)
+}
+
+// Schedule returns the upcoming events of the assets sorted by date. Each
+// asset has a maturity event, when its maturity date is known and not
+// passed, and a liquidity event, the day the money would be available if
+// a redemption was requested now, counting the D+n liquidity in B3
+// business days. Events within horizon of now are
+// flagged as due.
+func Schedule(assets []magnetis.Asset, now time.Time, horizon time.Duration) (events []Event) {
+	today := time.Date(now.Year(), now.Month(), now.Day(), 0, 0, 0, 0, time.UTC)
+	for _, asset := range assets {
+		if date, err := parseDate(asset.MaturityDate); err == nil && !date.Before(today) {
+			events = append(events, Event{Date: date, Kind: Maturity, Asset: asset})
+		}
+		events = append(events, Event{Date: calendar.AddBusinessDays(today, asset.Liquidity), Kind: Liquidity, Asset: asset})
+	}
+	for i := range events {
+		events[i].Due = events[i].Kind == Maturity && events[i].Date.Sub(today) <= horizon
+	}
+	sort.SliceStable(events, func(i, j int) bool { return events[i].Date.Before(events[j].Date) })
+	return
+}
+
+// ByMonth buckets sorted events by month.
+func ByMonth(events []Event) (months []Month) {
+	for _, e := range events {
+		month := time.Date(e.Date.Year(), e.Date.Month(), 1, 0, 0, 0, 0, time.UTC)
+		if len(months) == 0 || !months[len(months)-1].Month.Equal(month) {
+			months = append(months, Month{Month: month})
+		}
+		months[len(months)-1].Events = append(months[len(months)-1].Events, e)
+	}
+	return
+}
+
+// WriteICS writes the events as an iCalendar file with one all-day event each.
+func WriteICS(w io.Writer, events []Event, now time.Time) error {
+	var b strings.Builder
+	b.WriteString("BEGIN:VCALENDAR\r\n")
+	b.WriteString("VERSION:2.0\r\n")
+	b.WriteString("PRODID:-//magnetis-crawler//maturity calendar//EN\r\n")
+	b.WriteString("CALSCALE:GREGORIAN\r\n")
+	stamp := now.UTC().Format("20060102T150405Z")
+	for _, e := range events {
+		b.WriteString("BEGIN:VEVENT\r\n")
+		writeLine(&b, "UID:%d-%s-%s@magnetis-crawler", e.Asset.AssetID, strings.ToLower(e.Kind.String()), e.Date.Format("20060102"))
+		writeLine(&b, "DTSTAMP:%s", stamp)
+		writeLine(&b, "DTSTART;VALUE=DATE:%s", e.Date.Format("20060102"))
+		writeLine(&b, "DTEND;VALUE=DATE:%s", e.Date.AddDate(0, 0, 1).Format("20060102"))
+		writeLine(&b, "SUMMARY:%s", escape(fmt.Sprintf("%s: %s", e.Kind, e.Asset.Name)))
+		writeLine(&b, "DESCRIPTION:%s", escape(fmt.Sprintf("%s %s - R$ %s", e.Asset.InstrumentTypeName, e.Asset.Issuer, e.Asset.Amount)))
+		b.WriteString("END:VEVENT\r\n")
+	}
+	b.WriteString("END:VCALENDAR\r\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// maxLine is the longest content line allowed by RFC 5545, in octets
+const maxLine = 75
+
+// writeLine writes a content line folded at maxLine octets, continuing on
+// lines that start with a space and never splitting a UTF-8 character.
+func writeLine(b *strings.Builder, format string, args ...interface{}) {
+	line := fmt.Sprintf(format, args...)
+	limit := maxLine
+	for len(line) > limit {
+		cut := limit
+		for cut > 0 && !utf8.RuneStart(line[cut]) {
+			cut--
+		}
+		b.WriteString(line[:cut])
+		b.WriteString("\r\n ")
+		line = line[cut:]
+		limit = maxLine - 1
+	}
+	b.WriteString(line)
+	b.WriteString("\r\n")
+}
+
+var icsEscaper = strings.NewReplacer(`\`, `\\`, ";", `\;`, ",", `\,`, "\n", `\n`)
+
+func escape(text string) string {
+	return icsEscaper.Replace(text)
+}
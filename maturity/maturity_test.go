@@ -0,0 +1,86 @@
+package maturity
+
+import (
+	"strings"
+	"testing"
+	"time"
+	"unicode/utf8"
+
+	"github.com/alfredosegundo/magnetis-crawler/magnetis"
+)
+
+func TestSchedule(t *testing.T) {
+	now := time.Date(2024, 2, 9, 15, 0, 0, 0, time.UTC)
+	assets := []magnetis.Asset{
+		{Name: "CDB", MaturityDate: "2024-03-01", Liquidity: 1},
+		{Name: "Vencido", MaturityDate: "2024-01-01"},
+		{Name: "LCI", MaturityDate: "15/06/2024", Liquidity: 2},
+		{Name: "Fundo", MaturityDate: "", Liquidity: 30},
+	}
+	want := []string{
+		"2024-02-09\tLiquidity\tVencido\t",
+		"2024-02-14\tLiquidity\tCDB\t",
+		"2024-02-15\tLiquidity\tLCI\t",
+		"2024-03-01\tMaturity\tCDB\t\t!",
+		"2024-03-26\tLiquidity\tFundo\t",
+		"2024-06-15\tMaturity\tLCI\t",
+	}
+	events := Schedule(assets, now, 30*24*time.Hour)
+	var got []string
+	for _, e := range events {
+		got = append(got, e.String())
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("Schedule =\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+	months := ByMonth(events)
+	if len(months) != 3 || len(months[0].Events) != 3 || months[2].Month.Format("2006-01") != "2024-06" {
+		t.Errorf("ByMonth = %+v, want February, March and June", months)
+	}
+}
+
+func TestWriteICS(t *testing.T) {
+	events := []Event{{
+		Date: time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC),
+		Kind: Maturity,
+		Asset: magnetis.Asset{AssetID: 42, Name: "CDB Banco Intermediário de Investimentos, Pós-fixado 110% do CDI",
+			InstrumentTypeName: "CDB", Issuer: "Banco Inter", Amount: "1100.50"},
+	}}
+	var b strings.Builder
+	if err := WriteICS(&b, events, time.Date(2024, 2, 9, 12, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	want := "BEGIN:VCALENDAR\r\nVERSION:2.0\r\nPRODID:-//magnetis-crawler//maturity calendar//EN\r\nCALSCALE:GREGORIAN\r\n" +
+		"BEGIN:VEVENT\r\nUID:42-maturity-20240301@magnetis-crawler\r\nDTSTAMP:20240209T120000Z\r\n" +
+		"DTSTART;VALUE=DATE:20240301\r\nDTEND;VALUE=DATE:20240302\r\n" +
+		"SUMMARY:Maturity: CDB Banco Intermediário de Investimentos\\, Pós-fixado 1\r\n 10% do CDI\r\n" +
+		"DESCRIPTION:CDB Banco Inter - R$ 1100.50\r\nEND:VEVENT\r\nEND:VCALENDAR\r\n"
+	if got := b.String(); got != want {
+		t.Errorf("WriteICS =\n%q\nwant\n%q", got, want)
+	}
+}
+
+func TestWriteLineFolds(t *testing.T) {
+	tests := []string{
+		"SUMMARY:short",
+		"X:" + strings.Repeat("a", 73),
+		"X:" + strings.Repeat("a", 72) + "é" + strings.Repeat("b", 200),
+		"X:" + strings.Repeat("ç", 100),
+	}
+	for _, line := range tests {
+		var b strings.Builder
+		writeLine(&b, "%s", line)
+		folded := strings.TrimSuffix(b.String(), "\r\n")
+		for _, l := range strings.Split(folded, "\r\n") {
+			if len(l) > maxLine {
+				t.Errorf("writeLine left a %d octet line: %q", len(l), l)
+			}
+			if !utf8.ValidString(l) {
+				t.Errorf("writeLine split a character: %q", l)
+			}
+		}
+		if unfolded := strings.Replace(folded, "\r\n ", "", -1); unfolded != line {
+			t.Errorf("unfolded %q, want %q", unfolded, line)
+		}
+	}
+}
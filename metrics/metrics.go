@@ -0,0 +1,149 @@
+// Package metrics exports the stored portfolio and the crawler health in
+// the Prometheus text format.
+package metrics
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"io"
+	"log"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/alfredosegundo/magnetis-crawler/performance"
+	"github.com/alfredosegundo/magnetis-crawler/store"
+)
+
+// A Label is a name and value pair of a sample.
+type Label struct {
+	Name  string
+	Value string
+}
+
+// Writer writes metric families in the Prometheus text format.
+type Writer struct {
+	w    *bufio.Writer
+	seen map[string]bool
+}
+
+// NewWriter returns a writer to w; call Flush when done.
+func NewWriter(w io.Writer) *Writer {
+	return &Writer{w: bufio.NewWriter(w), seen: make(map[string]bool)}
+}
+
+// Sample writes a sample, preceded by the HELP and TYPE lines on the first
+// sample of the metric.
+func (w *Writer) Sample(name, kind, help string, value float64, labels ...Label) {
+	if !w.seen[name] {
+		w.seen[name] = true
+		fmt.Fprintf(w.w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+	}
+	w.w.WriteString(name)
+	if len(labels) > 0 {
+		w.w.WriteByte('{')
+		for i, l := range labels {
+			if i > 0 {
+				w.w.WriteByte(',')
+			}
+			fmt.Fprintf(w.w, "%s=\"%s\"", l.Name, escape(l.Value))
+		}
+		w.w.WriteByte('}')
+	}
+	w.w.WriteByte(' ')
+	w.w.WriteString(strconv.FormatFloat(value, 'g', -1, 64))
+	w.w.WriteByte('\n')
+}
+
+// Flush writes the buffered samples.
+func (w *Writer) Flush() error {
+	return w.w.Flush()
+}
+
+func escape(value string) string {
+	return strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`).Replace(value)
+}
+
+// Write exports the portfolio values, the stock quotes and the crawler
+// health of the store.
+func Write(out io.Writer, st *store.Store) error {
+	equities, err := st.Equities()
+	if err != nil {
+		return err
+	}
+	applications, err := st.Applications()
+	if err != nil {
+		return err
+	}
+	assets, err := st.Assets()
+	if err != nil {
+		return err
+	}
+	quotes, err := st.Quotes()
+	if err != nil {
+		return err
+	}
+	health, err := st.Health()
+	if err != nil {
+		return err
+	}
+
+	w := NewWriter(out)
+	if len(equities) > 0 {
+		p := performance.Compute(equities, applications).Point
+		w.Sample("magnetis_equity_brl", "gauge", "Current equity of the portfolio.", p.Equity)
+		w.Sample("magnetis_invested_brl", "gauge", "Total invested in the portfolio.", p.Invested)
+		w.Sample("magnetis_return_brl", "gauge", "Return of the portfolio, equity less invested.", p.Return)
+		w.Sample("magnetis_return_percent", "gauge", "Return of the portfolio over the invested amount.", p.ReturnPercent)
+		w.Sample("magnetis_equity_timestamp_seconds", "gauge", "Day of the last equity curve value.", float64(p.Date.Unix()))
+	}
+	for _, a := range assets {
+		amount, _ := strconv.ParseFloat(strings.TrimSpace(a.Amount), 64)
+		w.Sample("magnetis_asset_amount_brl", "gauge", "Amount held on each asset.", amount,
+			Label{"asset", a.Name}, Label{"category", a.CategoryKey}, Label{"issuer", a.Issuer}, Label{"instrument_type", a.InstrumentTypeName})
+	}
+	for _, q := range quotes {
+		w.Sample("magnetis_stock_price", "gauge", "Last quote of each stock.", q.Price, Label{"symbol", q.Symbol}, Label{"currency", q.Currency}, Label{"source", q.Source})
+	}
+	for _, q := range quotes {
+		w.Sample("magnetis_stock_quote_timestamp_seconds", "gauge", "Time of the last quote of each stock.", float64(q.Time.Unix()), Label{"symbol", q.Symbol})
+	}
+
+	w.Sample("magnetis_signin_failures_total", "counter", "Failed magnetis sign ins.", float64(health.SigninFailures))
+	jobs := make([]string, 0, len(health.Jobs))
+	for name := range health.Jobs {
+		jobs = append(jobs, name)
+	}
+	sort.Strings(jobs)
+	for _, name := range jobs {
+		w.Sample("magnetis_scrape_runs_total", "counter", "Runs of each crawler job.", float64(health.Jobs[name].Runs), Label{"job", name})
+	}
+	for _, name := range jobs {
+		w.Sample("magnetis_scrape_failures_total", "counter", "Failed runs of each crawler job.", float64(health.Jobs[name].Failures), Label{"job", name})
+	}
+	for _, name := range jobs {
+		w.Sample("magnetis_scrape_duration_seconds", "gauge", "Duration of the last run of each crawler job.", health.Jobs[name].Duration, Label{"job", name})
+	}
+	for _, name := range jobs {
+		if last := health.Jobs[name].LastSuccess; !last.IsZero() {
+			w.Sample("magnetis_last_success_timestamp_seconds", "gauge", "Time of the last successful run of each crawler job.", float64(last.Unix()), Label{"job", name})
+		}
+	}
+	return w.Flush()
+}
+
+// Handler serves the metrics of the store.
+func Handler(st *store.Store) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var b bytes.Buffer
+		if err := Write(&b, st); err != nil {
+			log.Printf("metrics: %v", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		w.Write(b.Bytes())
+	})
+}
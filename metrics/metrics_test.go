@@ -0,0 +1,98 @@
+package metrics
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alfredosegundo/magnetis-crawler/magnetis"
+	"github.com/alfredosegundo/magnetis-crawler/stocks"
+	"github.com/alfredosegundo/magnetis-crawler/store"
+)
+
+func TestWrite(t *testing.T) {
+	dir, err := ioutil.TempDir("", "metrics")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	st, err := store.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	quoted := time.Date(2024, 3, 1, 17, 0, 0, 0, time.UTC)
+	steps := []error{
+		st.SaveEquities([]magnetis.Equity{{Time: day.AddDate(0, 0, -1), Value: "1000"}, {Time: day, Value: "1100"}}),
+		st.SaveApplications([]magnetis.Application{{ApplicationDate: day.AddDate(0, -1, 0), Type: magnetis.MoneyApplication, Net: 1000}}),
+		st.SaveAssets([]magnetis.Asset{{Name: "CDB \"Banco\"", CategoryKey: "fixed_income", Issuer: "Banco", InstrumentTypeName: "CDB", Amount: "1100"}}),
+		st.SaveQuotes([]stocks.Quote{{Symbol: "PETR4", Price: 38.5, Currency: "BRL", Time: quoted, Source: "file"}}),
+		st.RecordSignin(errors.New("invalid password")),
+		st.RecordSignin(nil),
+		st.RecordJob("curve", 2*time.Second, nil),
+		st.RecordJob("stocks", 500*time.Millisecond, errors.New("timeout")),
+	}
+	for _, err := range steps {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	health, err := st.Health()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	var b bytes.Buffer
+	if err = Write(&b, st); err != nil {
+		t.Fatal(err)
+	}
+	want := `# HELP magnetis_equity_brl Current equity of the portfolio.
+# TYPE magnetis_equity_brl gauge
+magnetis_equity_brl 1100
+# HELP magnetis_invested_brl Total invested in the portfolio.
+# TYPE magnetis_invested_brl gauge
+magnetis_invested_brl 1000
+# HELP magnetis_return_brl Return of the portfolio, equity less invested.
+# TYPE magnetis_return_brl gauge
+magnetis_return_brl 100
+# HELP magnetis_return_percent Return of the portfolio over the invested amount.
+# TYPE magnetis_return_percent gauge
+magnetis_return_percent 10
+# HELP magnetis_equity_timestamp_seconds Day of the last equity curve value.
+# TYPE magnetis_equity_timestamp_seconds gauge
+magnetis_equity_timestamp_seconds 1.7092512e+09
+# HELP magnetis_asset_amount_brl Amount held on each asset.
+# TYPE magnetis_asset_amount_brl gauge
+magnetis_asset_amount_brl{asset="CDB \"Banco\"",category="fixed_income",issuer="Banco",instrument_type="CDB"} 1100
+# HELP magnetis_stock_price Last quote of each stock.
+# TYPE magnetis_stock_price gauge
+magnetis_stock_price{symbol="PETR4",currency="BRL",source="file"} 38.5
+# HELP magnetis_stock_quote_timestamp_seconds Time of the last quote of each stock.
+# TYPE magnetis_stock_quote_timestamp_seconds gauge
+magnetis_stock_quote_timestamp_seconds{symbol="PETR4"} 1.7093124e+09
+# HELP magnetis_signin_failures_total Failed magnetis sign ins.
+# TYPE magnetis_signin_failures_total counter
+magnetis_signin_failures_total 1
+# HELP magnetis_scrape_runs_total Runs of each crawler job.
+# TYPE magnetis_scrape_runs_total counter
+magnetis_scrape_runs_total{job="curve"} 1
+magnetis_scrape_runs_total{job="stocks"} 1
+# HELP magnetis_scrape_failures_total Failed runs of each crawler job.
+# TYPE magnetis_scrape_failures_total counter
+magnetis_scrape_failures_total{job="curve"} 0
+magnetis_scrape_failures_total{job="stocks"} 1
+# HELP magnetis_scrape_duration_seconds Duration of the last run of each crawler job.
+# TYPE magnetis_scrape_duration_seconds gauge
+magnetis_scrape_duration_seconds{job="curve"} 2
+magnetis_scrape_duration_seconds{job="stocks"} 0.5
+# HELP magnetis_last_success_timestamp_seconds Time of the last successful run of each crawler job.
+# TYPE magnetis_last_success_timestamp_seconds gauge
+` + fmt.Sprintf("magnetis_last_success_timestamp_seconds{job=\"curve\"} %v\n", float64(health.Jobs["curve"].LastSuccess.Unix()))
+	if got := b.String(); got != want {
+		t.Errorf("Write =\n%s\nwant\n%s", got, want)
+	}
+}
@@ -0,0 +1,188 @@
+// Package ofx exports the applications as an OFX investment statement and
+// as a QIF file, for personal finance apps.
+package ofx
+
+import (
+	"crypto/sha1"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"math"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alfredosegundo/magnetis-crawler/magnetis"
+)
+
+// BrokerID identifies magnetis on the statement
+const BrokerID = "magnetis.com.br"
+
+// SecurityID returns a stable ID for an investment.
+func SecurityID(investment string) string {
+	sum := sha1.Sum([]byte(strings.TrimSpace(investment)))
+	return strings.ToUpper(hex.EncodeToString(sum[:6]))
+}
+
+// transactionIDs returns the ID of each application, computed by
+// magnetis.IDs for the applications that don't have one.
+func transactionIDs(applications []magnetis.Application) []string {
+	ids := magnetis.IDs(applications)
+	for i, a := range applications {
+		if a.ID != "" {
+			ids[i] = a.ID
+		}
+	}
+	return ids
+}
+
+// Write writes an OFX 1.0.2 investment statement of the account. Purchases
+// are BUYOTHER, redemptions and expired titles SELLOTHER with the IR as
+// taxes, and IR withholdings and fees INVBANKTRAN debits.
+func Write(w io.Writer, account string, applications []magnetis.Application, now time.Time) error {
+	ids := transactionIDs(applications)
+	var b strings.Builder
+	b.WriteString("OFXHEADER:100\r\nDATA:OFXSGML\r\nVERSION:102\r\nSECURITY:NONE\r\nENCODING:UNICODE\r\nCHARSET:NONE\r\nCOMPRESSION:NONE\r\nOLDFILEUID:NONE\r\nNEWFILEUID:NONE\r\n\r\n")
+	b.WriteString("<OFX>\n<SIGNONMSGSRSV1><SONRS>\n<STATUS><CODE>0<SEVERITY>INFO</STATUS>\n")
+	fmt.Fprintf(&b, "<DTSERVER>%s<LANGUAGE>POR\n</SONRS></SIGNONMSGSRSV1>\n", dateTime(now))
+	b.WriteString("<INVSTMTMSGSRSV1><INVSTMTTRNRS><TRNUID>1<STATUS><CODE>0<SEVERITY>INFO</STATUS>\n")
+	fmt.Fprintf(&b, "<INVSTMTRS><DTASOF>%s<CURDEF>BRL\n", dateTime(now))
+	fmt.Fprintf(&b, "<INVACCTFROM><BROKERID>%s<ACCTID>%s</INVACCTFROM>\n", BrokerID, escape(account))
+
+	start, end := now, now
+	for _, a := range applications {
+		if d := tradeDate(a); !d.IsZero() && d.Before(start) {
+			start = d
+		}
+	}
+	fmt.Fprintf(&b, "<INVTRANLIST><DTSTART>%s<DTEND>%s\n", date(start), date(end))
+	securities := make(map[string]bool)
+	for i, a := range applications {
+		investment := strings.TrimSpace(a.Investment)
+		memo := strings.TrimSpace(fmt.Sprintf("%s %s", a.Type, investment))
+		invtran := fmt.Sprintf("<INVTRAN><FITID>%s<DTTRADE>%s<DTSETTLE>%s<MEMO>%s</INVTRAN>", ids[i], date(tradeDate(a)), date(settleDate(a)), escape(memo))
+		secid := fmt.Sprintf("<SECID><UNIQUEID>%s<UNIQUEIDTYPE>MAGNETIS</SECID>", SecurityID(investment))
+		// Magnetis signs the quantity and net either way, the sign comes from the type
+		net := math.Abs(a.Net)
+		switch a.Type {
+		case magnetis.MoneyApplication:
+			securities[investment] = true
+			fmt.Fprintf(&b, "<BUYOTHER><INVBUY>%s%s<UNITS>%s<UNITPRICE>%s<TOTAL>%s<SUBACCTSEC>CASH<SUBACCTFUND>CASH</INVBUY></BUYOTHER>\n",
+				invtran, secid, units(math.Abs(a.Quantity)), units(a.Price), amount(-net))
+		case magnetis.Redemption, magnetis.ExpiredTitle:
+			securities[investment] = true
+			fmt.Fprintf(&b, "<SELLOTHER><INVSELL>%s%s<UNITS>%s<UNITPRICE>%s<TAXES>%s<TOTAL>%s<SUBACCTSEC>CASH<SUBACCTFUND>CASH</INVSELL></SELLOTHER>\n",
+				invtran, secid, units(-math.Abs(a.Quantity)), units(a.Price), amount(math.Abs(a.IR)), amount(net))
+		case magnetis.IRWithdrawal, magnetis.AdvisoryFee, magnetis.TransactionFees:
+			value := net
+			if a.Type == magnetis.IRWithdrawal && a.IR != 0 {
+				value = math.Abs(a.IR)
+			}
+			fmt.Fprintf(&b, "<INVBANKTRAN><STMTTRN><TRNTYPE>%s<DTPOSTED>%s<TRNAMT>%s<FITID>%s<NAME>%s</STMTTRN><SUBACCTFUND>CASH</INVBANKTRAN>\n",
+				bankType(a.Type), date(settleDate(a)), amount(-value), ids[i], escape(truncate(memo, 32)))
+		}
+	}
+	b.WriteString("</INVTRANLIST>\n</INVSTMTRS></INVSTMTTRNRS></INVSTMTMSGSRSV1>\n")
+
+	if len(securities) > 0 {
+		names := make([]string, 0, len(securities))
+		for name := range securities {
+			names = append(names, name)
+		}
+		sort.Strings(names)
+		b.WriteString("<SECLISTMSGSRSV1><SECLIST>\n")
+		for _, name := range names {
+			fmt.Fprintf(&b, "<OTHERINFO><SECINFO><SECID><UNIQUEID>%s<UNIQUEIDTYPE>MAGNETIS</SECID><SECNAME>%s</SECINFO></OTHERINFO>\n",
+				SecurityID(name), escape(truncate(name, 120)))
+		}
+		b.WriteString("</SECLIST></SECLISTMSGSRSV1>\n")
+	}
+	b.WriteString("</OFX>\n")
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+// WriteQIF writes the applications as a QIF investment account. QIF has
+// no transaction IDs, so the ID goes in the memo.
+func WriteQIF(w io.Writer, applications []magnetis.Application) error {
+	ids := transactionIDs(applications)
+	var b strings.Builder
+	b.WriteString("!Type:Invst\n")
+	for i, a := range applications {
+		investment := strings.TrimSpace(a.Investment)
+		net := math.Abs(a.Net)
+		fmt.Fprintf(&b, "D%s\n", settleDate(a).Format("01/02/2006"))
+		switch a.Type {
+		case magnetis.MoneyApplication:
+			fmt.Fprintf(&b, "NBuy\nY%s\nI%s\nQ%s\nT%s\n", investment, units(a.Price), units(math.Abs(a.Quantity)), amount(net))
+		case magnetis.Redemption, magnetis.ExpiredTitle:
+			fmt.Fprintf(&b, "NSell\nY%s\nI%s\nQ%s\nT%s\n", investment, units(a.Price), units(math.Abs(a.Quantity)), amount(net))
+			if ir := math.Abs(a.IR); ir > 0 {
+				fmt.Fprintf(&b, "O%s\n", amount(ir))
+			}
+		default:
+			value := net
+			if a.Type == magnetis.IRWithdrawal && a.IR != 0 {
+				value = math.Abs(a.IR)
+			}
+			b.WriteString("NMiscExp\n")
+			if investment != "" {
+				fmt.Fprintf(&b, "Y%s\n", investment)
+			}
+			fmt.Fprintf(&b, "T%s\n", amount(value))
+		}
+		fmt.Fprintf(&b, "M%s [%s]\n^\n", strings.TrimSpace(fmt.Sprintf("%s %s", a.Type, investment)), ids[i])
+	}
+	_, err := io.WriteString(w, b.String())
+	return err
+}
+
+func tradeDate(a magnetis.Application) time.Time {
+	if a.ApplicationDate.IsZero() {
+		return a.Date
+	}
+	return a.ApplicationDate
+}
+
+func settleDate(a magnetis.Application) time.Time {
+	if a.Date.IsZero() {
+		return a.ApplicationDate
+	}
+	return a.Date
+}
+
+func bankType(t magnetis.TransactionType) string {
+	if t == magnetis.IRWithdrawal {
+		return "DEBIT"
+	}
+	return "FEE"
+}
+
+func date(t time.Time) string {
+	return t.Format("20060102")
+}
+
+func dateTime(t time.Time) string {
+	return t.Format("20060102150405")
+}
+
+func amount(value float64) string {
+	return fmt.Sprintf("%.2f", value)
+}
+
+func units(value float64) string {
+	return strconv.FormatFloat(value, 'f', -1, 64)
+}
+
+func escape(text string) string {
+	return strings.NewReplacer("&", "&amp;", "<", "&lt;", ">", "&gt;").Replace(text)
+}
+
+func truncate(text string, size int) string {
+	runes := []rune(text)
+	if len(runes) <= size {
+		return text
+	}
+	return string(runes[:size])
+}
@@ -0,0 +1,107 @@
+package ofx
+
+import (
+	"io/ioutil"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/alfredosegundo/magnetis-crawler/magnetis"
+)
+
+func day(month time.Month, d int) time.Time {
+	return time.Date(2024, month, d, 0, 0, 0, 0, time.UTC)
+}
+
+var applications = []magnetis.Application{
+	{ID: "a1", ApplicationDate: day(1, 10), Date: day(1, 11), Type: magnetis.MoneyApplication, Investment: "CDB Banco Inter", Quantity: 1, Price: 1000, Net: 1000},
+	{ID: "a2", ApplicationDate: day(1, 15), Date: day(1, 16), Type: magnetis.MoneyApplication, Investment: "Fundo <Ações> & Cia", Quantity: -10.5, Price: 20, Net: -210},
+	{ID: "a3", Date: day(2, 1), Type: magnetis.AdvisoryFee, Net: 3.5},
+	{ID: "a4", Date: day(5, 31), Type: magnetis.IRWithdrawal, Investment: "Fundo <Ações> & Cia", IR: -4.1},
+	{ID: "a5", ApplicationDate: day(6, 7), Date: day(6, 10), Type: magnetis.Redemption, Investment: "CDB Banco Inter", Quantity: -1, Price: 1100, IR: 22.5, Net: 1077.5},
+	{ID: "a6", Date: day(3, 1), Type: magnetis.ExpiredTitle, Investment: "LCI Banco", Quantity: 2, Price: 250, Net: 500},
+}
+
+func golden(t *testing.T, name, got string) {
+	want, err := ioutil.ReadFile(filepath.Join("testdata", name))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if got != string(want) {
+		t.Errorf("%s =\n%s\nwant\n%s", name, got, want)
+	}
+}
+
+func TestWrite(t *testing.T) {
+	var b strings.Builder
+	if err := Write(&b, "12345", applications, time.Date(2024, 7, 1, 12, 30, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	golden(t, "statement.ofx", b.String())
+}
+
+func TestWriteQIF(t *testing.T) {
+	var b strings.Builder
+	if err := WriteQIF(&b, applications); err != nil {
+		t.Fatal(err)
+	}
+	golden(t, "statement.qif", b.String())
+}
+
+func TestTransactionIDs(t *testing.T) {
+	stored := []magnetis.Application{{ID: "stored", Investment: "CDB"}, {Investment: "CDB"}}
+	ids := transactionIDs(stored)
+	if ids[0] != "stored" || ids[1] != magnetis.IDs(stored)[1] {
+		t.Errorf("transactionIDs = %v, want the stored ID and a computed one", ids)
+	}
+}
+
+var fitid = regexp.MustCompile(`<FITID>([^<]+)`)
+
+// fitids returns the FITIDs of the statement of the stored history after
+// merging the scrape, like the statement commands do.
+func fitids(t *testing.T, stored, scrape []magnetis.Application) map[string]bool {
+	merged, _, err := magnetis.Merge(stored, scrape)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var b strings.Builder
+	if err = Write(&b, "12345", merged, time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)); err != nil {
+		t.Fatal(err)
+	}
+	ids := make(map[string]bool)
+	for _, m := range fitid.FindAllStringSubmatch(b.String(), -1) {
+		ids[m[1]] = true
+	}
+	return ids
+}
+
+func TestFITIDsStable(t *testing.T) {
+	var scrape []magnetis.Application
+	for _, a := range applications {
+		a.ID = "" // As scraped, before the stored IDs are known
+		scrape = append(scrape, a)
+	}
+	// The stored IDs a1 to a6 can't be computed from the scrape, they are
+	// only kept by merging into the stored history
+	before := fitids(t, applications, scrape)
+	for _, a := range applications {
+		if !before[a.ID] {
+			t.Errorf("FITID %s of the stored history not exported", a.ID)
+		}
+	}
+
+	// A new fee, identical to an older one, shows up earlier in the history
+	inserted := append([]magnetis.Application{scrape[0], scrape[2]}, scrape[1:]...)
+	after := fitids(t, applications, inserted)
+	for id := range before {
+		if !after[id] {
+			t.Errorf("FITID %s changed after a row was inserted", id)
+		}
+	}
+	if len(after) != len(before)+1 {
+		t.Errorf("%d FITIDs after the insert, want %d", len(after), len(before)+1)
+	}
+}
@@ -0,0 +1,97 @@
+// Package performance computes the portfolio returns from the equity
+// curve and the applications.
+package performance
+
+import (
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alfredosegundo/magnetis-crawler/magnetis"
+)
+
+// A Point is the portfolio on a day of the equity curve. Percentages go
+// from 0 to 100.
+type Point struct {
+	Date          time.Time `json:"date"`
+	Equity        float64   `json:"equity"`
+	Invested      float64   `json:"invested"`
+	Return        float64   `json:"return"`
+	ReturnPercent float64   `json:"return_percent"`
+}
+
+// A Month is the return over a calendar month.
+type Month struct {
+	Month         string  `json:"month"` // YYYY-MM
+	Equity        float64 `json:"equity"`
+	Return        float64 `json:"return"`
+	ReturnPercent float64 `json:"return_percent"`
+}
+
+// Summary is the last point of the curve and its history.
+type Summary struct {
+	Point
+	Points []Point `json:"points"`
+	Months []Month `json:"months"`
+}
+
+// Invested sums the money put in the portfolio up to the day, like the
+// "Total Aplicado" column of the spreadsheet: applications and fees less
+// redemptions and expired titles.
+func Invested(applications []magnetis.Application, day time.Time) (total float64) {
+	for _, a := range applications {
+		if a.ApplicationDate.After(day) {
+			continue
+		}
+		switch a.Type {
+		case magnetis.MoneyApplication, magnetis.AdvisoryFee, magnetis.TransactionFees:
+			total += a.Net
+		case magnetis.Redemption, magnetis.ExpiredTitle:
+			total -= a.Net
+		}
+	}
+	return total
+}
+
+// Compute returns the performance of the sorted equity curve.
+func Compute(equities []magnetis.Equity, applications []magnetis.Application) Summary {
+	var s Summary
+	for _, e := range equities {
+		p := Point{Date: e.Time, Equity: parseValue(e.Value), Invested: Invested(applications, e.Time)}
+		p.Return = p.Equity - p.Invested
+		if p.Invested != 0 {
+			p.ReturnPercent = p.Return / p.Invested * 100
+		}
+		s.Points = append(s.Points, p)
+	}
+	if len(s.Points) == 0 {
+		return s
+	}
+	s.Point = s.Points[len(s.Points)-1]
+
+	var previous *Point
+	for i := range s.Points {
+		p := &s.Points[i]
+		last := i == len(s.Points)-1 || s.Points[i+1].Date.Format("2006-01") != p.Date.Format("2006-01")
+		if !last {
+			continue
+		}
+		m := Month{Month: p.Date.Format("2006-01"), Equity: p.Equity, Return: p.Return}
+		if previous != nil {
+			m.Return = p.Return - previous.Return
+			if previous.Equity != 0 {
+				m.ReturnPercent = m.Return / previous.Equity * 100
+			}
+		} else if p.Invested != 0 {
+			m.ReturnPercent = p.Return / p.Invested * 100
+		}
+		s.Months = append(s.Months, m)
+		previous = p
+	}
+	return s
+}
+
+func parseValue(value string) float64 {
+	v, _ := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	return v
+}
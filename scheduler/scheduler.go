@@ -0,0 +1,246 @@
+package scheduler
+
+import (
+	"context"
+	"encoding/json"
+	"io/ioutil"
+	"log"
+	"math/rand"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	"github.com/alfredosegundo/magnetis-crawler/calendar"
+)
+
+// An Entry is a job run on a schedule.
+type Entry struct {
+	Name        string
+	Schedule    Schedule
+	TradingDays bool // Skip weekends and holidays
+	MarketHours bool // Skip runs while B3 is closed
+	Run         func() error
+}
+
+// Status is the last run of an entry, saved on the status file.
+type Status struct {
+	Name      string    `json:"name"`
+	Status    string    `json:"status"` // ok, error, running or interrupted
+	LastStart time.Time `json:"last_start,omitempty"`
+	LastEnd   time.Time `json:"last_end,omitempty"`
+	Duration  string    `json:"duration,omitempty"`
+	Error     string    `json:"error,omitempty"`
+	NextRun   time.Time `json:"next_run,omitempty"`
+	Runs      int       `json:"runs"`
+	Failures  int       `json:"failures"`
+	Skipped   int       `json:"skipped"`
+}
+
+// Scheduler runs the entries until its context is canceled. A run is
+// skipped while the previous run of the same entry hasn't finished.
+type Scheduler struct {
+	Entries    []Entry
+	Jitter     time.Duration      // Random delay added to each start
+	Location   *time.Location     // Time zone of the schedules, local when nil
+	StatusPath string             // JSON file with the last run of each entry, optional
+	Calendar   *calendar.Calendar // Trading days and hours, the B3 calendar when nil
+
+	mu      sync.Mutex
+	status  map[string]*Status
+	running map[string]bool
+	saving  sync.Mutex
+}
+
+// Run schedules the entries and blocks until ctx is done, then waits for
+// the running jobs to finish.
+func (s *Scheduler) Run(ctx context.Context) error {
+	s.status = make(map[string]*Status)
+	s.running = make(map[string]bool)
+	for _, e := range s.Entries {
+		s.status[e.Name] = &Status{Name: e.Name}
+	}
+	s.load()
+
+	var wg sync.WaitGroup
+	for _, e := range s.Entries {
+		wg.Add(1)
+		go func(e Entry) {
+			defer wg.Done()
+			s.loop(ctx, e, &wg)
+		}(e)
+	}
+	<-ctx.Done()
+	wg.Wait()
+	return s.save()
+}
+
+func (s *Scheduler) loop(ctx context.Context, e Entry, wg *sync.WaitGroup) {
+	for {
+		next := s.next(e, s.now())
+		if next.IsZero() {
+			log.Printf("scheduler: %s never runs again", e.Name)
+			return
+		}
+		var delay time.Duration
+		if s.Jitter > 0 {
+			delay = time.Duration(rand.Int63n(int64(s.Jitter)))
+		}
+		s.update(e.Name, func(st *Status) { st.NextRun = next })
+		timer := time.NewTimer(time.Until(next) + delay)
+		select {
+		case <-ctx.Done():
+			timer.Stop()
+			return
+		case <-timer.C:
+		}
+		if !s.start(e.Name) {
+			log.Printf("scheduler: %s is still running, skipping", e.Name)
+			s.update(e.Name, func(st *Status) { st.Skipped++ })
+			continue
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			s.run(e)
+		}()
+	}
+}
+
+// next returns the next run after t, skipping non trading days and closed
+// market hours when required.
+func (s *Scheduler) next(e Entry, t time.Time) time.Time {
+	cal := s.Calendar
+	if cal == nil {
+		cal = calendar.New()
+	}
+	limit := t.AddDate(1, 0, 0)
+	for {
+		t = e.Schedule.Next(t)
+		switch {
+		case t.IsZero():
+			return t
+		case t.After(limit):
+			return time.Time{}
+		case (e.TradingDays || e.MarketHours) && !cal.IsBusinessDay(t):
+			t = time.Date(t.Year(), t.Month(), t.Day(), 23, 59, 0, 0, t.Location())
+		case e.MarketHours && !cal.IsOpen(t):
+			if open := cal.NextOpen(t).In(t.Location()); open.After(t) {
+				t = open.Add(-time.Minute)
+			}
+		default:
+			return t
+		}
+	}
+}
+
+func (s *Scheduler) now() time.Time {
+	if s.Location == nil {
+		return time.Now()
+	}
+	return time.Now().In(s.Location)
+}
+
+// start marks the entry as running, unless it already is.
+func (s *Scheduler) start(name string) bool {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.running[name] {
+		return false
+	}
+	s.running[name] = true
+	return true
+}
+
+func (s *Scheduler) run(e Entry) {
+	start := time.Now()
+	s.update(e.Name, func(st *Status) {
+		st.Status = "running"
+		st.LastStart = start
+		st.Error = ""
+	})
+	log.Printf("scheduler: running %s", e.Name)
+	err := e.Run()
+	s.mu.Lock()
+	s.running[e.Name] = false
+	s.mu.Unlock()
+	s.update(e.Name, func(st *Status) {
+		st.LastEnd = time.Now()
+		st.Duration = st.LastEnd.Sub(start).String()
+		st.Runs++
+		st.Status = "ok"
+		if err != nil {
+			st.Status = "error"
+			st.Error = err.Error()
+			st.Failures++
+		}
+	})
+	if err != nil {
+		log.Printf("scheduler: %s failed: %v", e.Name, err)
+	} else {
+		log.Printf("scheduler: %s done in %s", e.Name, time.Since(start))
+	}
+}
+
+// update changes the entry status and saves the status file.
+func (s *Scheduler) update(name string, change func(*Status)) {
+	s.mu.Lock()
+	change(s.status[name])
+	s.mu.Unlock()
+	if err := s.save(); err != nil {
+		log.Printf("scheduler: %v", err)
+	}
+}
+
+// Statuses returns the status of each entry sorted by name.
+func (s *Scheduler) Statuses() []Status {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	statuses := make([]Status, 0, len(s.status))
+	for _, st := range s.status {
+		statuses = append(statuses, *st)
+	}
+	sort.Slice(statuses, func(i, j int) bool { return statuses[i].Name < statuses[j].Name })
+	return statuses
+}
+
+func (s *Scheduler) save() error {
+	if s.StatusPath == "" {
+		return nil
+	}
+	s.saving.Lock()
+	defer s.saving.Unlock()
+	b, err := json.MarshalIndent(s.Statuses(), "", "  ")
+	if err != nil {
+		return err
+	}
+	tmp := s.StatusPath + ".tmp"
+	if err = ioutil.WriteFile(tmp, b, 0600); err != nil {
+		return err
+	}
+	return os.Rename(tmp, s.StatusPath)
+}
+
+// load keeps the run counters of the previous status file.
+func (s *Scheduler) load() {
+	if s.StatusPath == "" {
+		return
+	}
+	b, err := ioutil.ReadFile(s.StatusPath)
+	if err != nil {
+		return
+	}
+	var previous []Status
+	if err = json.Unmarshal(b, &previous); err != nil {
+		log.Printf("scheduler: ignoring status file %s: %v", s.StatusPath, err)
+		return
+	}
+	for _, p := range previous {
+		if st, ok := s.status[p.Name]; ok {
+			*st = p
+			if st.Status == "running" {
+				st.Status = "interrupted"
+			}
+		}
+	}
+}
@@ -0,0 +1,99 @@
+package scheduler
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestConcurrentRuns(t *testing.T) {
+	dir, err := ioutil.TempDir("", "scheduler")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	s := &Scheduler{StatusPath: filepath.Join(dir, "status.json")}
+	var mu sync.Mutex
+	calls := make(map[string]int)
+	for i := 0; i < 4; i++ {
+		name := fmt.Sprintf("job%d", i)
+		s.Entries = append(s.Entries, Entry{Name: name, Run: func() error {
+			mu.Lock()
+			calls[name]++
+			mu.Unlock()
+			if name == "job0" {
+				return fmt.Errorf("failed")
+			}
+			return nil
+		}})
+	}
+	s.status = make(map[string]*Status)
+	s.running = make(map[string]bool)
+	for _, e := range s.Entries {
+		s.status[e.Name] = &Status{Name: e.Name}
+	}
+
+	var wg sync.WaitGroup
+	for round := 0; round < 5; round++ {
+		for _, e := range s.Entries {
+			wg.Add(1)
+			go func(e Entry) {
+				defer wg.Done()
+				for !s.start(e.Name) {
+					time.Sleep(time.Millisecond)
+				}
+				s.run(e)
+			}(e)
+		}
+	}
+	wg.Wait()
+
+	b, err := ioutil.ReadFile(s.StatusPath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved []Status
+	if err = json.Unmarshal(b, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if len(saved) != len(s.Entries) {
+		t.Fatalf("status file has %d entries, want %d", len(saved), len(s.Entries))
+	}
+	for _, st := range saved {
+		wantStatus, wantFailures := "ok", 0
+		if st.Name == "job0" {
+			wantStatus, wantFailures = "error", 5
+		}
+		if st.Runs != 5 || st.Failures != wantFailures || st.Status != wantStatus || calls[st.Name] != 5 {
+			t.Errorf("%s: %d calls, status %+v", st.Name, calls[st.Name], st)
+		}
+	}
+}
+
+func TestNextSkipsClosedDays(t *testing.T) {
+	schedule, err := Parse("0 12 * * *")
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := &Scheduler{}
+	// Friday before Carnival 2024, B3 closes on Monday and Tuesday
+	friday := time.Date(2024, 2, 9, 13, 0, 0, 0, time.UTC)
+	tests := []struct {
+		entry Entry
+		want  time.Time
+	}{
+		{Entry{Schedule: schedule}, time.Date(2024, 2, 10, 12, 0, 0, 0, time.UTC)},
+		{Entry{Schedule: schedule, TradingDays: true}, time.Date(2024, 2, 14, 12, 0, 0, 0, time.UTC)},
+	}
+	for _, tt := range tests {
+		if got := s.next(tt.entry, friday); !got.Equal(tt.want) {
+			t.Errorf("next(trading days %v) = %v, want %v", tt.entry.TradingDays, got, tt.want)
+		}
+	}
+}
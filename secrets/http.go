@@ -0,0 +1,66 @@
+package secrets
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+)
+
+// HTTP fetches secrets from an endpoint answering GET URL/name. The
+// response may be the plain secret or a JSON object with the secret in a
+// "value" or, like AWS Secrets Manager, a "SecretString" field.
+type HTTP struct {
+	URL    string
+	Token  string // Sent as a bearer token when set
+	Client *http.Client
+}
+
+// Secret requests the secret from the endpoint.
+func (h HTTP) Secret(name string) (string, error) {
+	client := h.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+	req, err := http.NewRequest("GET", strings.TrimRight(h.URL, "/")+"/"+url.PathEscape(name), nil)
+	if err != nil {
+		return "", err
+	}
+	if h.Token != "" {
+		req.Header.Set("Authorization", "Bearer "+h.Token)
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return "", fmt.Errorf("Failed to read response body: %v", err)
+	}
+	if resp.StatusCode == http.StatusNotFound {
+		return "", ErrNotFound
+	}
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("secret %s: http status code: %d", name, resp.StatusCode)
+	}
+	if strings.HasPrefix(resp.Header.Get("Content-Type"), "application/json") {
+		var secret struct {
+			Value        *string `json:"value"`
+			SecretString *string `json:"SecretString"`
+		}
+		if err = json.Unmarshal(body, &secret); err != nil {
+			return "", fmt.Errorf("Failed to unmarshal secret %s: %v", name, err)
+		}
+		switch {
+		case secret.Value != nil:
+			return *secret.Value, nil
+		case secret.SecretString != nil:
+			return *secret.SecretString, nil
+		}
+		return "", fmt.Errorf("secret %s: no value in response", name)
+	}
+	return string(body), nil
+}
@@ -0,0 +1,59 @@
+package secrets
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestHTTP(t *testing.T) {
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("Authorization") != "Bearer token" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		switch r.URL.Path {
+		case "/plain":
+			w.Write([]byte("plain value"))
+		case "/value":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{"value": "json value"}`))
+		case "/aws":
+			w.Header().Set("Content-Type", "application/json; charset=utf-8")
+			w.Write([]byte(`{"Name": "aws", "SecretString": "aws value"}`))
+		case "/empty":
+			w.Header().Set("Content-Type", "application/json")
+			w.Write([]byte(`{}`))
+		case "/broken":
+			w.WriteHeader(http.StatusInternalServerError)
+		default:
+			http.NotFound(w, r)
+		}
+	}))
+	defer server.Close()
+
+	provider := HTTP{URL: server.URL + "/", Token: "token"}
+	tests := []struct {
+		name  string
+		value string
+		err   bool
+	}{
+		{"plain", "plain value", false},
+		{"value", "json value", false},
+		{"aws", "aws value", false},
+		{"empty", "", true},
+		{"broken", "", true},
+	}
+	for _, tt := range tests {
+		value, err := provider.Secret(tt.name)
+		if value != tt.value || (err != nil) != tt.err {
+			t.Errorf("Secret(%q) = %q, %v, want %q and error %v", tt.name, value, err, tt.value, tt.err)
+		}
+	}
+	if _, err := provider.Secret("missing"); err != ErrNotFound {
+		t.Errorf("Secret(missing) error = %v, want ErrNotFound", err)
+	}
+	if _, err := (HTTP{URL: server.URL}).Secret("plain"); err == nil || err == ErrNotFound {
+		t.Errorf("Secret without token error = %v, want an unauthorized error", err)
+	}
+}
@@ -0,0 +1,137 @@
+// Package secrets looks up the credentials used by the crawler from
+// environment variables, files, an encrypted local vault or an HTTP
+// secrets endpoint.
+package secrets
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+)
+
+// Names of the secrets used by the crawler
+const (
+	MagnetisUser     = "MAGNETIS_USER"
+	MagnetisPassword = "MAGNETIS_PASS"
+	ClientSecret     = "CLIENT_SECRET"
+	Credentials      = "CREDENTIALS"
+)
+
+// ErrNotFound is returned by providers that don't have the secret.
+var ErrNotFound = errors.New("secret not found")
+
+// A Provider returns the value of a secret by name.
+type Provider interface {
+	Secret(name string) (string, error)
+}
+
+// Get returns the secret value or an empty string when it is not found.
+// Errors other than ErrNotFound are returned.
+func Get(p Provider, name string) (string, error) {
+	value, err := p.Secret(name)
+	if err == ErrNotFound {
+		return "", nil
+	}
+	return value, err
+}
+
+// Chain asks each provider in order until one has the secret.
+type Chain []Provider
+
+// Secret returns the value of the first provider that has the secret.
+func (c Chain) Secret(name string) (string, error) {
+	for _, p := range c {
+		value, err := p.Secret(name)
+		if err != ErrNotFound {
+			return value, err
+		}
+	}
+	return "", ErrNotFound
+}
+
+// Env reads secrets from environment variables named Prefix+name.
+type Env struct {
+	Prefix string
+}
+
+// Secret returns the environment variable of the secret.
+func (e Env) Secret(name string) (string, error) {
+	if value, ok := os.LookupEnv(e.Prefix + name); ok {
+		return value, nil
+	}
+	return "", ErrNotFound
+}
+
+// Files reads each secret from a file in Dir. The file is named after the
+// secret unless Names maps the secret to another file name. When Only is
+// set, secrets missing from Names are not looked up.
+type Files struct {
+	Dir   string
+	Names map[string]string
+	Only  bool
+}
+
+// Secret returns the content of the secret file without trailing newlines.
+func (f Files) Secret(name string) (string, error) {
+	file, ok := f.Names[name]
+	if !ok {
+		if f.Only {
+			return "", ErrNotFound
+		}
+		file = name
+	}
+	b, err := ioutil.ReadFile(filepath.Join(f.Dir, file))
+	if os.IsNotExist(err) {
+		return "", ErrNotFound
+	}
+	if err != nil {
+		return "", fmt.Errorf("Failed to read secret %s: %v", name, err)
+	}
+	return strings.TrimRight(string(b), "\r\n"), nil
+}
+
+// Options configures the providers of the chain returned by New.
+type Options struct {
+	VaultPath       string // Encrypted vault, skipped when empty
+	VaultPassphrase string
+	URL             string // HTTP secrets endpoint, skipped when empty
+	Token           string
+}
+
+// OptionsFromEnv reads the options from MAGNETIS_VAULT,
+// MAGNETIS_VAULT_PASSPHRASE, SECRETS_URL and SECRETS_TOKEN.
+func OptionsFromEnv() Options {
+	return Options{
+		VaultPath:       os.Getenv("MAGNETIS_VAULT"),
+		VaultPassphrase: os.Getenv("MAGNETIS_VAULT_PASSPHRASE"),
+		URL:             os.Getenv("SECRETS_URL"),
+		Token:           os.Getenv("SECRETS_TOKEN"),
+	}
+}
+
+// New returns the chain used by the crawler: client_secret.json on the
+// working directory, environment variables, then the vault and the HTTP
+// endpoint when configured. A configured vault must exist.
+func New(o Options) (Chain, error) {
+	chain := Chain{
+		Files{Dir: ".", Names: map[string]string{ClientSecret: "client_secret.json"}, Only: true},
+		Env{},
+	}
+	if o.VaultPath != "" {
+		vault, err := OpenVault(o.VaultPath, o.VaultPassphrase)
+		if os.IsNotExist(err) {
+			return nil, fmt.Errorf("Vault %s doesn't exist, create it with the vault set command", o.VaultPath)
+		}
+		if err != nil {
+			return nil, err
+		}
+		chain = append(chain, vault)
+	}
+	if o.URL != "" {
+		chain = append(chain, HTTP{URL: o.URL, Token: o.Token})
+	}
+	return chain, nil
+}
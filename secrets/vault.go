@@ -0,0 +1,131 @@
+package secrets
+
+import (
+	"bytes"
+	"crypto/rand"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+
+	"golang.org/x/crypto/nacl/secretbox"
+	"golang.org/x/crypto/scrypt"
+)
+
+var vaultMagic = []byte("MCVAULT1")
+
+const (
+	saltSize  = 16
+	nonceSize = 24
+	keySize   = 32
+)
+
+// A Vault is a local file holding secrets encrypted with NaCl secretbox,
+// with the key derived from a passphrase by scrypt.
+type Vault struct {
+	Path    string
+	key     [keySize]byte
+	salt    []byte
+	secrets map[string]string
+}
+
+// NewVault returns an empty vault, written to path on Save.
+func NewVault(path string, passphrase string) (*Vault, error) {
+	v := &Vault{Path: path, secrets: make(map[string]string), salt: make([]byte, saltSize)}
+	if _, err := io.ReadFull(rand.Reader, v.salt); err != nil {
+		return nil, err
+	}
+	return v, v.deriveKey(passphrase)
+}
+
+// OpenVault decrypts the vault file with the passphrase. A missing file is
+// an error, check it with os.IsNotExist.
+func OpenVault(path string, passphrase string) (*Vault, error) {
+	v := &Vault{Path: path, secrets: make(map[string]string)}
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	header := len(vaultMagic) + saltSize + nonceSize
+	if len(b) < header+secretbox.Overhead || !bytes.Equal(b[:len(vaultMagic)], vaultMagic) {
+		return nil, fmt.Errorf("%s is not a secrets vault", path)
+	}
+	v.salt = b[len(vaultMagic) : len(vaultMagic)+saltSize]
+	if err = v.deriveKey(passphrase); err != nil {
+		return nil, err
+	}
+	var nonce [nonceSize]byte
+	copy(nonce[:], b[len(vaultMagic)+saltSize:header])
+	plain, ok := secretbox.Open(nil, b[header:], &nonce, &v.key)
+	if !ok {
+		return nil, fmt.Errorf("Unable to unlock vault %s: wrong passphrase or corrupted file", path)
+	}
+	if err = json.Unmarshal(plain, &v.secrets); err != nil {
+		return nil, fmt.Errorf("Unable to read vault %s: %v", path, err)
+	}
+	return v, nil
+}
+
+func (v *Vault) deriveKey(passphrase string) error {
+	key, err := scrypt.Key([]byte(passphrase), v.salt, 1<<15, 8, 1, keySize)
+	if err != nil {
+		return err
+	}
+	copy(v.key[:], key)
+	return nil
+}
+
+// Secret returns the value stored in the vault.
+func (v *Vault) Secret(name string) (string, error) {
+	if value, ok := v.secrets[name]; ok {
+		return value, nil
+	}
+	return "", ErrNotFound
+}
+
+// Set stores a secret, call Save to persist it.
+func (v *Vault) Set(name string, value string) {
+	v.secrets[name] = value
+}
+
+// Delete removes a secret, call Save to persist it.
+func (v *Vault) Delete(name string) {
+	delete(v.secrets, name)
+}
+
+// Names returns the names of the stored secrets.
+func (v *Vault) Names() []string {
+	names := make([]string, 0, len(v.secrets))
+	for name := range v.secrets {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// Save encrypts the secrets with a new nonce and replaces the vault file.
+func (v *Vault) Save() error {
+	plain, err := json.Marshal(v.secrets)
+	if err != nil {
+		return err
+	}
+	var nonce [nonceSize]byte
+	if _, err = io.ReadFull(rand.Reader, nonce[:]); err != nil {
+		return err
+	}
+	out := append([]byte{}, vaultMagic...)
+	out = append(out, v.salt...)
+	out = append(out, nonce[:]...)
+	out = secretbox.Seal(out, plain, &nonce, &v.key)
+	if err = os.MkdirAll(filepath.Dir(v.Path), 0700); err != nil {
+		return err
+	}
+	if err = ioutil.WriteFile(v.Path+".tmp", out, 0600); err != nil {
+		return err
+	}
+	return os.Rename(v.Path+".tmp", v.Path)
+}
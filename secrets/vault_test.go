@@ -0,0 +1,50 @@
+package secrets
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestVault(t *testing.T) {
+	dir, err := ioutil.TempDir("", "vault")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	path := filepath.Join(dir, "secrets.vault")
+
+	if _, err = OpenVault(path, "passphrase"); !os.IsNotExist(err) {
+		t.Fatalf("OpenVault of a missing file error = %v, want not exist", err)
+	}
+	if _, err = New(Options{VaultPath: path, VaultPassphrase: "passphrase"}); err == nil {
+		t.Fatalf("New with a missing vault should fail")
+	}
+
+	v, err := NewVault(path, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	v.Set(MagnetisUser, "user@example.com")
+	if err = v.Save(); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = os.Stat(path + ".tmp"); !os.IsNotExist(err) {
+		t.Errorf("temporary vault file left behind: %v", err)
+	}
+
+	v, err = OpenVault(path, "passphrase")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if value, err := v.Secret(MagnetisUser); err != nil || value != "user@example.com" {
+		t.Errorf("Secret = %q, %v, want user@example.com", value, err)
+	}
+	if _, err = v.Secret(MagnetisPassword); err != ErrNotFound {
+		t.Errorf("missing secret error = %v, want ErrNotFound", err)
+	}
+	if _, err = OpenVault(path, "wrong"); err == nil {
+		t.Errorf("OpenVault with a wrong passphrase should fail")
+	}
+}
@@ -0,0 +1,110 @@
+package server
+
+import "net/http"
+
+// dashboard serves the page at /, which draws the data of the API.
+func dashboard(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/" {
+		http.NotFound(w, r)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Write([]byte(dashboardHTML))
+}
+
+const dashboardHTML = `<!DOCTYPE html>
+<html lang="pt-BR">
+<head>
+<meta charset="utf-8">
+<title>Magnetis Crawler</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+h1 { font-size: 1.4em; }
+section { margin-bottom: 2em; }
+.summary span { display: inline-block; margin-right: 2em; }
+.summary b { display: block; font-size: 1.3em; }
+table { border-collapse: collapse; }
+td, th { padding: 4px 10px; border-bottom: 1px solid #ddd; text-align: left; }
+.legend div { margin: 2px 0; }
+.legend i { display: inline-block; width: 12px; height: 12px; margin-right: 6px; }
+</style>
+</head>
+<body>
+<h1>Magnetis Crawler</h1>
+<section class="summary" id="summary"></section>
+<section>
+<h2>Patrimônio</h2>
+<canvas id="equity" width="900" height="300"></canvas>
+</section>
+<section>
+<h2>Alocação</h2>
+<canvas id="allocation" width="300" height="300" style="float: left"></canvas>
+<div class="legend" id="legend" style="float: left; margin-left: 2em"></div>
+<div style="clear: both"></div>
+</section>
+<section>
+<h2>Próximos vencimentos</h2>
+<table id="maturities"><tr><th>Data</th><th>Ativo</th><th>Emissor</th><th>Valor</th></tr></table>
+</section>
+<script>
+var colors = ["#4e79a7", "#f28e2b", "#e15759", "#76b7b2", "#59a14f", "#edc948", "#b07aa1", "#ff9da7", "#9c755f", "#bab0ac"];
+function money(v) { return "R$ " + v.toLocaleString("pt-BR", {minimumFractionDigits: 2, maximumFractionDigits: 2}); }
+function percent(v) { return v.toFixed(2) + "%"; }
+function get(path) { return fetch(path).then(function (r) { return r.json(); }); }
+
+get("/performance").then(function (p) {
+	document.getElementById("summary").innerHTML =
+		"<span>Saldo<b>" + money(p.equity) + "</b></span>" +
+		"<span>Aplicado<b>" + money(p.invested) + "</b></span>" +
+		"<span>Retorno<b>" + money(p["return"]) + " (" + percent(p.return_percent) + ")</b></span>";
+	var points = p.points || [];
+	if (points.length < 2) return;
+	var canvas = document.getElementById("equity"), ctx = canvas.getContext("2d");
+	var values = points.map(function (x) { return x.equity; }).concat(points.map(function (x) { return x.invested; }));
+	var min = Math.min.apply(null, values), max = Math.max.apply(null, values);
+	function line(field, color) {
+		ctx.beginPath();
+		ctx.strokeStyle = color;
+		points.forEach(function (x, i) {
+			var px = i / (points.length - 1) * (canvas.width - 20) + 10;
+			var py = canvas.height - 10 - (x[field] - min) / (max - min || 1) * (canvas.height - 20);
+			if (i === 0) ctx.moveTo(px, py); else ctx.lineTo(px, py);
+		});
+		ctx.stroke();
+	}
+	line("invested", colors[1]);
+	line("equity", colors[0]);
+});
+
+get("/allocation").then(function (slices) {
+	var canvas = document.getElementById("allocation"), ctx = canvas.getContext("2d");
+	var start = -Math.PI / 2, legend = document.getElementById("legend");
+	slices.forEach(function (s, i) {
+		var color = colors[i % colors.length], end = start + s.percent / 100 * 2 * Math.PI;
+		ctx.beginPath();
+		ctx.moveTo(150, 150);
+		ctx.arc(150, 150, 140, start, end);
+		ctx.fillStyle = color;
+		ctx.fill();
+		start = end;
+		var item = document.createElement("div"), swatch = document.createElement("i");
+		swatch.style.background = color;
+		item.appendChild(swatch);
+		item.appendChild(document.createTextNode(s.key + " " + percent(s.percent)));
+		legend.appendChild(item);
+	});
+});
+
+get("/maturities").then(function (events) {
+	var table = document.getElementById("maturities");
+	events.forEach(function (e) {
+		var row = table.insertRow();
+		[e.date.substring(0, 10), e.asset, e.issuer, e.amount].forEach(function (v) {
+			row.insertCell().textContent = v;
+		});
+	});
+});
+</script>
+</body>
+</html>
+`
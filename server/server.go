@@ -0,0 +1,183 @@
+// Package server serves the stored data as a JSON API and a web dashboard.
+package server
+
+import (
+	"encoding/json"
+	"log"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alfredosegundo/magnetis-crawler/allocation"
+	"github.com/alfredosegundo/magnetis-crawler/magnetis"
+	"github.com/alfredosegundo/magnetis-crawler/maturity"
+	"github.com/alfredosegundo/magnetis-crawler/performance"
+	"github.com/alfredosegundo/magnetis-crawler/store"
+)
+
+// DefaultHorizon is how far ahead /maturities looks when no days are given.
+const DefaultHorizon = 90 * 24 * time.Hour
+
+// Equity is a day of the equity curve.
+type Equity struct {
+	Date  time.Time `json:"date"`
+	Value float64   `json:"value"`
+}
+
+// Application is an application as served by the API.
+type Application struct {
+	ID              string    `json:"id"`
+	ApplicationDate time.Time `json:"application_date"`
+	Date            time.Time `json:"date"`
+	Type            string    `json:"type"`
+	Investment      string    `json:"investment"`
+	Quantity        float64   `json:"quantity"`
+	Price           float64   `json:"price"`
+	IR              float64   `json:"ir"`
+	Net             float64   `json:"net"`
+	Owner           string    `json:"owner,omitempty"`
+}
+
+// Slice is a share of the portfolio in the allocation breakdown.
+type Slice struct {
+	Key     string  `json:"key"`
+	Amount  float64 `json:"amount"`
+	Percent float64 `json:"percent"`
+}
+
+// Event is an upcoming maturity of an asset.
+type Event struct {
+	Date   time.Time `json:"date"`
+	Asset  string    `json:"asset"`
+	Issuer string    `json:"issuer"`
+	Amount string    `json:"amount"`
+}
+
+// Handler returns the API and dashboard routes over the store. More
+// routes, like /metrics, can be added to the returned mux.
+func Handler(st *store.Store) *http.ServeMux {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", dashboard)
+	mux.HandleFunc("/equity", func(w http.ResponseWriter, r *http.Request) {
+		equities, err := st.Equities()
+		if err != nil {
+			fail(w, err)
+			return
+		}
+		v := make([]Equity, 0, len(equities))
+		for _, e := range equities {
+			value, _ := strconv.ParseFloat(strings.TrimSpace(e.Value), 64)
+			v = append(v, Equity{Date: e.Time, Value: value})
+		}
+		respond(w, v)
+	})
+	mux.HandleFunc("/applications", func(w http.ResponseWriter, r *http.Request) {
+		applications, err := st.Applications()
+		if err != nil {
+			fail(w, err)
+			return
+		}
+		v := make([]Application, 0, len(applications))
+		for _, a := range applications {
+			v = append(v, Application{
+				ID: a.ID, ApplicationDate: a.ApplicationDate, Date: a.Date, Type: a.Type.String(), Investment: strings.TrimSpace(a.Investment),
+				Quantity: a.Quantity, Price: a.Price, IR: a.IR, Net: a.Net, Owner: a.Owner,
+			})
+		}
+		respond(w, v)
+	})
+	mux.HandleFunc("/assets", func(w http.ResponseWriter, r *http.Request) {
+		assets, err := st.Assets()
+		if err != nil {
+			fail(w, err)
+			return
+		}
+		if assets == nil {
+			assets = []magnetis.Asset{}
+		}
+		respond(w, assets)
+	})
+	mux.HandleFunc("/plan", func(w http.ResponseWriter, r *http.Request) {
+		plan, err := st.Plan()
+		if err != nil {
+			fail(w, err)
+			return
+		}
+		if plan == nil {
+			http.Error(w, "no investment plan saved", http.StatusNotFound)
+			return
+		}
+		respond(w, plan)
+	})
+	mux.HandleFunc("/performance", func(w http.ResponseWriter, r *http.Request) {
+		equities, err := st.Equities()
+		if err != nil {
+			fail(w, err)
+			return
+		}
+		applications, err := st.Applications()
+		if err != nil {
+			fail(w, err)
+			return
+		}
+		respond(w, performance.Compute(equities, applications))
+	})
+	mux.HandleFunc("/allocation", func(w http.ResponseWriter, r *http.Request) {
+		dimension := allocation.Category
+		if name := r.URL.Query().Get("by"); name != "" {
+			var err error
+			if dimension, err = allocation.ParseDimension(name); err != nil {
+				http.Error(w, err.Error(), http.StatusBadRequest)
+				return
+			}
+		}
+		assets, err := st.Assets()
+		if err != nil {
+			fail(w, err)
+			return
+		}
+		v := []Slice{}
+		for _, s := range allocation.Breakdown(assets, dimension) {
+			v = append(v, Slice{Key: s.Key, Amount: s.Amount, Percent: s.Percent})
+		}
+		respond(w, v)
+	})
+	mux.HandleFunc("/maturities", func(w http.ResponseWriter, r *http.Request) {
+		horizon := DefaultHorizon
+		if days := r.URL.Query().Get("days"); days != "" {
+			n, err := strconv.Atoi(days)
+			if err != nil || n < 0 {
+				http.Error(w, "days must be a positive number", http.StatusBadRequest)
+				return
+			}
+			horizon = time.Duration(n) * 24 * time.Hour
+		}
+		assets, err := st.Assets()
+		if err != nil {
+			fail(w, err)
+			return
+		}
+		v := []Event{}
+		for _, e := range maturity.Schedule(assets, time.Now(), horizon) {
+			if !e.Due {
+				continue
+			}
+			v = append(v, Event{Date: e.Date, Asset: e.Asset.Name, Issuer: e.Asset.Issuer, Amount: e.Asset.Amount})
+		}
+		respond(w, v)
+	})
+	return mux
+}
+
+func respond(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	if err := json.NewEncoder(w).Encode(v); err != nil {
+		log.Printf("server: %v", err)
+	}
+}
+
+func fail(w http.ResponseWriter, err error) {
+	log.Printf("server: %v", err)
+	http.Error(w, err.Error(), http.StatusInternalServerError)
+}
@@ -0,0 +1,87 @@
+package server
+
+import (
+	"encoding/json"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/alfredosegundo/magnetis-crawler/magnetis"
+	"github.com/alfredosegundo/magnetis-crawler/store"
+)
+
+func TestAPI(t *testing.T) {
+	dir, err := ioutil.TempDir("", "server")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	st, err := store.Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	day := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	if err = st.SaveEquities([]magnetis.Equity{{Time: day, Value: " 1100.5 "}}); err != nil {
+		t.Fatal(err)
+	}
+	if _, err = st.MergeApplications([]magnetis.Application{{Date: day, ApplicationDate: day, Type: magnetis.MoneyApplication,
+		Investment: " CDB Banco ", Quantity: 1, Price: 1000, Net: 1000, Owner: "ana"}}); err != nil {
+		t.Fatal(err)
+	}
+	if err = st.SaveAssets([]magnetis.Asset{{Name: "CDB Banco", CategoryKey: "fixed_income", Amount: "600"}, {Name: "Ações", CategoryKey: "stocks", Amount: "400"}}); err != nil {
+		t.Fatal(err)
+	}
+	server := httptest.NewServer(Handler(st))
+	defer server.Close()
+
+	get := func(path string, v interface{}) int {
+		resp, err := http.Get(server.URL + path)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer resp.Body.Close()
+		if v != nil && resp.StatusCode == http.StatusOK {
+			if ct := resp.Header.Get("Content-Type"); ct != "application/json" {
+				t.Errorf("%s Content-Type = %s", path, ct)
+			}
+			if err = json.NewDecoder(resp.Body).Decode(v); err != nil {
+				t.Errorf("%s: %v", path, err)
+			}
+		}
+		return resp.StatusCode
+	}
+
+	var equities []Equity
+	if code := get("/equity", &equities); code != http.StatusOK || len(equities) != 1 || equities[0].Value != 1100.5 || !equities[0].Date.Equal(day) {
+		t.Errorf("/equity = %d %+v", code, equities)
+	}
+	var applications []Application
+	if code := get("/applications", &applications); code != http.StatusOK || len(applications) != 1 {
+		t.Fatalf("/applications = %d %+v", code, applications)
+	}
+	if a := applications[0]; a.ID == "" || a.Investment != "CDB Banco" || a.Type != magnetis.MoneyApplication.String() || a.Owner != "ana" || a.Net != 1000 {
+		t.Errorf("/applications = %+v", a)
+	}
+	var slices []Slice
+	if code := get("/allocation", &slices); code != http.StatusOK || len(slices) != 2 || slices[0].Key != "fixed_income" || slices[0].Percent != 60 {
+		t.Errorf("/allocation = %d %+v", code, slices)
+	}
+	tests := []struct {
+		path string
+		code int
+	}{
+		{"/allocation?by=color", http.StatusBadRequest},
+		{"/maturities?days=-1", http.StatusBadRequest},
+		{"/maturities?days=30", http.StatusOK},
+		{"/plan", http.StatusNotFound},
+		{"/performance", http.StatusOK},
+	}
+	for _, tt := range tests {
+		if code := get(tt.path, nil); code != tt.code {
+			t.Errorf("GET %s = %d, want %d", tt.path, code, tt.code)
+		}
+	}
+}
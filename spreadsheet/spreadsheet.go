@@ -2,7 +2,6 @@ package spreadsheet
 
 import (
 	"fmt"
-	"io/ioutil"
 	"log"
 
 	"net/http"
@@ -15,35 +14,95 @@ import (
 
 	"os/user"
 
+	"strconv"
 	"strings"
+	"time"
+	"unicode"
 
+	"github.com/alfredosegundo/magnetis-crawler/calendar"
 	"github.com/alfredosegundo/magnetis-crawler/magnetis"
+	"github.com/alfredosegundo/magnetis-crawler/secrets"
+	"github.com/alfredosegundo/magnetis-crawler/stocks"
 	"golang.org/x/net/context"
 	"golang.org/x/oauth2"
 	"golang.org/x/oauth2/google"
 	"google.golang.org/api/sheets/v4"
 )
 
-var client *http.Client
-var ctx context.Context
+// A Service writes the crawled data to google spreadsheets.
+type Service struct {
+	Layout Layout
+	sheets *sheets.Service
+	ctx    context.Context
+}
+
+// Layout holds the names of the spreadsheet tabs
+type Layout struct {
+	Curve        string // Equity curve and returns
+	Applications string // Application history
+	Invested     string // Money invested by date, filled by hand; when empty it's summed from the applications
+	Assets       string
+	Plan         string
+	Stocks       string // Stocks watchlist and quotes
+}
+
+// DefaultLayout is the layout of the original spreadsheet
+var DefaultLayout = Layout{
+	Curve:        "Rendimento",
+	Applications: "Historico",
+	Invested:     "Aplicado",
+	Assets:       "Ativos",
+	Plan:         "Plano",
+	Stocks:       "Acoes",
+}
+
+// NewLayout returns the default layout with the tab names given by
+// content: curve, applications, invested, assets, plan or stocks.
+func NewLayout(tabs map[string]string) Layout {
+	l := DefaultLayout
+	fields := map[string]*string{
+		"curve":        &l.Curve,
+		"applications": &l.Applications,
+		"invested":     &l.Invested,
+		"assets":       &l.Assets,
+		"plan":         &l.Plan,
+		"stocks":       &l.Stocks,
+	}
+	for key, name := range tabs {
+		if field, ok := fields[key]; ok && name != "" {
+			*field = name
+		}
+	}
+	return l
+}
+
+// tab returns the name of a tab as used on ranges and formulas.
+func tab(name string) string {
+	for _, r := range name {
+		if !unicode.IsLetter(r) && !unicode.IsDigit(r) && r != '_' {
+			return "'" + strings.Replace(name, "'", "''", -1) + "'"
+		}
+	}
+	return name
+}
 
 const firstRow = 2
 
-func getTokenFromWeb(config *oauth2.Config) *oauth2.Token {
+func getTokenFromWeb(config *oauth2.Config) (*oauth2.Token, error) {
 	authURL := config.AuthCodeURL("state-token", oauth2.AccessTypeOffline)
 	fmt.Printf("Go to the following link in your browser then type the "+
 		"authorization code: \n%v\n", authURL)
 
 	var code string
 	if _, err := fmt.Scan(&code); err != nil {
-		log.Fatalf("Unable to read authorization code %v", err)
+		return nil, fmt.Errorf("Unable to read authorization code %v", err)
 	}
 
 	tok, err := config.Exchange(oauth2.NoContext, code)
 	if err != nil {
-		log.Fatalf("Unable to retrieve token from web %v", err)
+		return nil, fmt.Errorf("Unable to retrieve token from web %v", err)
 	}
-	return tok
+	return tok, nil
 }
 
 func tokenCacheFile() (string, error) {
@@ -57,14 +116,18 @@ func tokenCacheFile() (string, error) {
 		url.QueryEscape("credentials.json")), nil
 }
 
-func tokenFromFile(file string) (*oauth2.Token, error) {
+func tokenFromFile(file string, secret secrets.Provider) (*oauth2.Token, error) {
 	var f *os.File
 	var err error
 	t := &oauth2.Token{}
 	if f, err = os.Open(file); err != nil {
-		if envvar, exist := os.LookupEnv("CREDENTIALS"); exist {
-			err = json.NewDecoder(strings.NewReader(envvar)).Decode(t)
-			return t, nil
+		credentials, secretErr := secrets.Get(secret, secrets.Credentials)
+		if secretErr != nil {
+			return t, secretErr
+		}
+		if credentials != "" {
+			err = json.NewDecoder(strings.NewReader(credentials)).Decode(t)
+			return t, err
 		}
 		return t, err
 	}
@@ -73,67 +136,89 @@ func tokenFromFile(file string) (*oauth2.Token, error) {
 	return t, err
 }
 
-func saveToken(file string, token *oauth2.Token) {
+func saveToken(file string, token *oauth2.Token) error {
 	fmt.Printf("Saving credential file to: %s\n", file)
 	f, err := os.OpenFile(file, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
 	if err != nil {
-		log.Fatalf("Unable to cache oauth token: %v", err)
+		return fmt.Errorf("Unable to cache oauth token: %v", err)
 	}
 	defer f.Close()
-	json.NewEncoder(f).Encode(token)
+	return json.NewEncoder(f).Encode(token)
 }
 
-func getClient(ctx context.Context, config *oauth2.Config) *http.Client {
+func getClient(ctx context.Context, config *oauth2.Config, secret secrets.Provider) (*http.Client, error) {
 	cacheFile, err := tokenCacheFile()
 	if err != nil {
-		log.Fatalf("Unable to get path to cached credential file. %v", err)
+		return nil, fmt.Errorf("Unable to get path to cached credential file. %v", err)
 	}
-	tok, err := tokenFromFile(cacheFile)
+	tok, err := tokenFromFile(cacheFile, secret)
 	if err != nil {
 		log.Println("token cache file not found.")
-		tok = getTokenFromWeb(config)
-		saveToken(cacheFile, tok)
+		if tok, err = getTokenFromWeb(config); err != nil {
+			return nil, err
+		}
+		if err = saveToken(cacheFile, tok); err != nil {
+			return nil, err
+		}
 	}
-	return config.Client(ctx, tok)
+	return config.Client(ctx, tok), nil
 }
 
-func SpreadsheetsSignin() {
+// SpreadsheetsSignin authorizes the access to google spreadsheets with the
+// client secret and the oauth token, cached or taken from the secrets.
+func SpreadsheetsSignin(secret secrets.Provider) (*Service, error) {
 	ctx := context.Background()
-	var b []byte
-	var err error
-	if b, err = ioutil.ReadFile("client_secret.json"); err != nil {
-		if envvar, exist := os.LookupEnv("CLIENT_SECRET"); exist {
-			b = []byte(envvar)
-		} else {
-			log.Fatalf("Unable to read client secret env var. It's not set.")
-		}
+	clientSecret, err := secrets.Get(secret, secrets.ClientSecret)
+	if err != nil {
+		return nil, err
+	}
+	if clientSecret == "" {
+		return nil, fmt.Errorf("Unable to read client secret. It's not set.")
 	}
+	b := []byte(clientSecret)
 
 	// If modifying these scopes, delete your previously saved credentials
 	// at ~/.credentials/sheets.googleapis.com-go-quickstart.json
 	config, err := google.ConfigFromJSON(b, "https://www.googleapis.com/auth/spreadsheets")
 	if err != nil {
-		log.Fatalf("Unable to parse client secret file to config: %v", err)
+		return nil, fmt.Errorf("Unable to parse client secret file to config: %v", err)
+	}
+	client, err := getClient(ctx, config, secret)
+	if err != nil {
+		return nil, err
+	}
+	service, err := sheets.New(client)
+	if err != nil {
+		return nil, err
 	}
-	client = getClient(ctx, config)
+	return &Service{Layout: DefaultLayout, sheets: service, ctx: ctx}, nil
 }
 
-func sumAsset(firstRow int, currentRow int, assetName magnetis.TransactionType) (formula string) {
-	return fmt.Sprintf("SUMIFS(Historico!$H$%v:$H,Historico!$A$%v:$A,\"<=\"&$A%v,Historico!$C$%v:$C,\"=%v\")", firstRow, firstRow, currentRow, firstRow, assetName)
+func sumAsset(sheet string, firstRow int, currentRow int, assetName magnetis.TransactionType) (formula string) {
+	return fmt.Sprintf("SUMIFS(%[1]s!$H$%[2]v:$H,%[1]s!$A$%[2]v:$A,\"<=\"&$A%[3]v,%[1]s!$C$%[2]v:$C,\"=%[4]v\")", sheet, firstRow, currentRow, assetName)
 }
 
-func UpdateEquityCurve(equities []magnetis.Equity, spreadsheetID string) (err error) {
+func (s *Service) UpdateEquityCurve(equities []magnetis.Equity, spreadsheetID string) (err error) {
 	rowsCount := len(equities) + 1
+	invested, applications := tab(s.Layout.Invested), tab(s.Layout.Applications)
 	v := make([][]interface{}, rowsCount)
 	v[0] = append(v[0], "Data", "Saldo Atual", "Total Aplicado", "Retorno", "Retorno dia", "Retorno dia %",
-		"Retorno desde início", "R$/R$ investido", "Mês", "Ano", "Total Aplicado")
+		"Retorno desde início", "R$/R$ investido", "Mês", "Ano", "Total Aplicado", "Dias úteis", "Retorno dia útil %")
 	for i, equity := range equities {
 		currentSlicePos := i + 1
 		currentRow := firstRow + i
+		businessDays := 0
+		if i > 0 {
+			businessDays = calendar.BusinessDaysBetween(equities[i-1].Time, equity.Time)
+		}
+		total := fmt.Sprintf("=K%d", currentRow)
+		if s.Layout.Invested != "" {
+			total = fmt.Sprintf("=SUMIF(%[1]s!$A$%[2]d:A,\"<=\"&A%[3]d,%[1]s!$B$%[2]d:B)", invested, firstRow, currentRow)
+		}
 		v[currentSlicePos] = append(v[currentSlicePos],
 			fmt.Sprintf("=DATE(%d,%d,%d)", equity.Time.Year(), equity.Time.Month(), equity.Time.Day()),
 			fmt.Sprintf("=%s", equity.Value),
-			fmt.Sprintf("=SUMIF(Aplicado!$A$%d:A,\"<=\"&A%d,Aplicado!$B$%d:B)", firstRow, currentRow, firstRow),
+			total,
 			fmt.Sprintf("=B%d-C%d", currentRow, currentRow),
 			fmt.Sprintf("=D%d-%s", currentRow, previousRow(currentRow)),
 			fmt.Sprintf("=E%d/B%d", currentRow, currentRow),
@@ -142,14 +227,16 @@ func UpdateEquityCurve(equities []magnetis.Equity, spreadsheetID string) (err er
 			fmt.Sprintf("=%d", equity.Time.Month()),
 			fmt.Sprintf("=%d", equity.Time.Year()),
 			fmt.Sprintf("=%v-%v-%v+%v+%v",
-				sumAsset(firstRow, currentRow, magnetis.MoneyApplication),
-				sumAsset(firstRow, currentRow, magnetis.Redemption),
-				sumAsset(firstRow, currentRow, magnetis.ExpiredTitle),
-				sumAsset(firstRow, currentRow, magnetis.AdvisoryFee),
-				sumAsset(firstRow, currentRow, magnetis.TransactionFees)))
+				sumAsset(applications, firstRow, currentRow, magnetis.MoneyApplication),
+				sumAsset(applications, firstRow, currentRow, magnetis.Redemption),
+				sumAsset(applications, firstRow, currentRow, magnetis.ExpiredTitle),
+				sumAsset(applications, firstRow, currentRow, magnetis.AdvisoryFee),
+				sumAsset(applications, firstRow, currentRow, magnetis.TransactionFees)),
+			fmt.Sprintf("=%d", businessDays),
+			fmt.Sprintf("=IF(L%[1]d>0,(1+F%[1]d)^(1/L%[1]d)-1,0)", currentRow))
 	}
 
-	return updateSpreadSheet(v, spreadsheetID, fmt.Sprintf("Rendimento!A1:K%v", rowsCount))
+	return s.updateSpreadSheet(v, spreadsheetID, fmt.Sprintf("%s!A1:M%v", tab(s.Layout.Curve), rowsCount))
 }
 
 func previousRow(currentRow int) (previousRow string) {
@@ -159,10 +246,17 @@ func previousRow(currentRow int) (previousRow string) {
 	return fmt.Sprintf("D%d", currentRow-1)
 }
 
-func UpdateApplications(applications []magnetis.Application, spreadsheetID string) (err error) {
+func (s *Service) UpdateApplications(applications []magnetis.Application, spreadsheetID string) (err error) {
+	withOwner := false
+	for _, application := range applications {
+		withOwner = withOwner || application.Owner != ""
+	}
 	rowsCount := len(applications) + 1
 	v := make([][]interface{}, rowsCount)
 	v[0] = append(v[0], "Data aplicação", "Data efetivação", "Tipo da transação", "Investimento", "Quantidade", "Preço (R$)", "IR (R$)", "Total Líquido (R$)")
+	if withOwner {
+		v[0] = append(v[0], "Titular")
+	}
 
 	for i := range applications {
 		application := applications[i]
@@ -176,28 +270,177 @@ func UpdateApplications(applications []magnetis.Application, spreadsheetID strin
 			fmt.Sprintf("=%f", application.IR),
 			fmt.Sprintf("=%f", application.Net),
 		)
+		if withOwner {
+			v[i+1] = append(v[i+1], application.Owner)
+		}
+	}
+	if withOwner {
+		return s.updateSpreadSheet(v, spreadsheetID, fmt.Sprintf("%s!A1:I%v", tab(s.Layout.Applications), rowsCount))
 	}
-	return updateSpreadSheet(v, spreadsheetID, fmt.Sprintf("Historico!A1:H%v", rowsCount))
+	return s.updateSpreadSheet(v, spreadsheetID, fmt.Sprintf("%s!A1:H%v", tab(s.Layout.Applications), rowsCount))
 }
 
-func updateSpreadSheet(values [][]interface{}, spreadsheetID string, valuesRange string) (err error) {
-	service, err := sheets.New(client)
+func (s *Service) UpdateAssets(assets []magnetis.Asset, spreadsheetID string) (err error) {
+	rowsCount := len(assets) + 1
+	v := make([][]interface{}, rowsCount)
+	v[0] = append(v[0], "Ativo", "Categoria", "Tipo", "Emissor", "Valor (R$)", "Retorno", "Rentabilidade", "Vencimento", "Liquidez (dias)")
+	for i, asset := range assets {
+		v[i+1] = append(v[i+1],
+			strings.TrimSpace(asset.Name),
+			asset.CategoryKey,
+			asset.InstrumentTypeName,
+			asset.Issuer,
+			fmt.Sprintf("=%s", asset.Amount),
+			asset.AssetReturn,
+			asset.Yield,
+			asset.MaturityDate,
+			asset.Liquidity,
+		)
+	}
+	return s.updateSpreadSheet(v, spreadsheetID, fmt.Sprintf("%s!A1:I%v", tab(s.Layout.Assets), rowsCount))
+}
+
+func (s *Service) UpdateInvestmentPlan(plan *magnetis.InvestmentPlan, spreadsheetID string) (err error) {
+	v := [][]interface{}{
+		{"Idade", "Objetivo (R$)", "Investimento inicial (R$)", "Investimento mensal (R$)", "Prazo (anos)", "Nível de risco"},
+		{plan.Age, plan.GoalValue, plan.InitialInvestment, plan.MonthlyInvestment, plan.PeriodInYears, plan.RiskLevel},
+	}
+	return s.updateSpreadSheet(v, spreadsheetID, tab(s.Layout.Plan)+"!A1:F2")
+}
+
+// EnsureTabs adds the tabs missing from the spreadsheet.
+func (s *Service) EnsureTabs(spreadsheetID string, names ...string) error {
+	spreadsheet, err := s.sheets.Spreadsheets.Get(spreadsheetID).Fields("sheets.properties.title").Context(s.ctx).Do()
 	if err != nil {
 		return err
 	}
+	existing := make(map[string]bool)
+	for _, sheet := range spreadsheet.Sheets {
+		existing[sheet.Properties.Title] = true
+	}
+	var requests []*sheets.Request
+	for _, name := range names {
+		if !existing[name] {
+			existing[name] = true
+			requests = append(requests, &sheets.Request{AddSheet: &sheets.AddSheetRequest{Properties: &sheets.SheetProperties{Title: name}}})
+		}
+	}
+	if len(requests) == 0 {
+		return nil
+	}
+	_, err = s.sheets.Spreadsheets.BatchUpdate(spreadsheetID, &sheets.BatchUpdateSpreadsheetRequest{Requests: requests}).Context(s.ctx).Do()
+	return err
+}
+
+func (s *Service) updateSpreadSheet(values [][]interface{}, spreadsheetID string, valuesRange string) (err error) {
 	rb := &sheets.ValueRange{Values: values, MajorDimension: "ROWS"}
 	valueInputOption := "USER_ENTERED"
-	_, err = service.Spreadsheets.Values.Update(spreadsheetID, valuesRange, rb).ValueInputOption(valueInputOption).Context(ctx).Do()
+	_, err = s.sheets.Spreadsheets.Values.Update(spreadsheetID, valuesRange, rb).ValueInputOption(valueInputOption).Context(s.ctx).Do()
 	if err != nil {
 		return err
 	}
 	return
 }
 
-func GetConfiguredStocks() (stocks []string) {
-	return []string{"VVAR3", "MGLU3"}
+// GetConfiguredStocks reads the stock codes listed on the first column of
+// the stocks tab, below the header.
+func (s *Service) GetConfiguredStocks(spreadsheetID string) (codes []string, err error) {
+	rows, err := s.readSpreadSheet(spreadsheetID, tab(s.Layout.Stocks)+"!A2:A")
+	if err != nil {
+		return nil, err
+	}
+	for _, row := range rows {
+		if len(row) == 0 {
+			continue
+		}
+		if code := strings.TrimSpace(fmt.Sprint(row[0])); code != "" {
+			codes = append(codes, strings.ToUpper(code))
+		}
+	}
+	return
+}
+
+// UpdateStocks writes the price, the update time and the daily change of
+// each quote on the row of its code on the stocks tab, in a single batch.
+// Codes not found on the tab are appended after the last row. When the
+// quote doesn't know the previous close, the price already on the tab is
+// used if it was written on an earlier day.
+func (s *Service) UpdateStocks(quotes []stocks.Quote, spreadsheetID string) (err error) {
+	rows, err := s.readSpreadSheet(spreadsheetID, tab(s.Layout.Stocks)+"!A2:D")
+	if err != nil {
+		return err
+	}
+	positions := make(map[string]int)
+	for i, row := range rows {
+		if len(row) > 0 {
+			positions[strings.ToUpper(strings.TrimSpace(fmt.Sprint(row[0])))] = i
+		}
+	}
+
+	data := []*sheets.ValueRange{{
+		Range:  tab(s.Layout.Stocks) + "!A1:D1",
+		Values: [][]interface{}{{"Código", "Preço", "Atualizado em", "Variação dia"}},
+	}}
+	next := len(rows)
+	for _, quote := range quotes {
+		i, found := positions[quote.Symbol]
+		if !found {
+			i = next
+			next++
+		}
+		change := ""
+		if quote.PreviousClose > 0 {
+			change = fmt.Sprintf("=%f", quote.Change())
+		} else if found {
+			change = previousChange(rows[i], quote)
+		}
+		row := firstRow + i
+		data = append(data, &sheets.ValueRange{
+			Range: fmt.Sprintf("%s!A%d:D%d", tab(s.Layout.Stocks), row, row),
+			Values: [][]interface{}{{
+				quote.Symbol,
+				fmt.Sprintf("=%f", quote.Price),
+				quote.Time.Format("2006-01-02 15:04:05"),
+				change,
+			}},
+		})
+	}
+
+	rb := &sheets.BatchUpdateValuesRequest{Data: data, ValueInputOption: "USER_ENTERED"}
+	_, err = s.sheets.Spreadsheets.Values.BatchUpdate(spreadsheetID, rb).Context(s.ctx).Do()
+	return err
+}
+
+// previousChange computes the daily change from the price stored on the
+// tab, keeping the current change when that price is from today.
+func previousChange(row []interface{}, quote stocks.Quote) string {
+	cell := func(i int) string {
+		if i < len(row) {
+			return strings.TrimSpace(fmt.Sprint(row[i]))
+		}
+		return ""
+	}
+	serial, err := strconv.ParseFloat(cell(2), 64)
+	if err != nil {
+		return cell(3)
+	}
+	// Dates are read as serial numbers, days since 1899-12-30
+	updated := time.Date(1899, time.December, 30, 0, 0, 0, 0, quote.Time.Location()).Add(time.Duration(serial * float64(24*time.Hour)))
+	y, m, d := quote.Time.Date()
+	if !updated.Before(time.Date(y, m, d, 0, 0, 0, 0, quote.Time.Location())) {
+		return cell(3)
+	}
+	previous, err := strconv.ParseFloat(cell(1), 64)
+	if err != nil || previous == 0 {
+		return cell(3)
+	}
+	return fmt.Sprintf("=%f", quote.Price/previous-1)
 }
 
-func UpdateStocks(stock string, value string) {
-	fmt.Printf("%s: %s\n", stock, value)
+func (s *Service) readSpreadSheet(spreadsheetID string, valuesRange string) (values [][]interface{}, err error) {
+	resp, err := s.sheets.Spreadsheets.Values.Get(spreadsheetID, valuesRange).ValueRenderOption("UNFORMATTED_VALUE").Context(s.ctx).Do()
+	if err != nil {
+		return nil, err
+	}
+	return resp.Values, nil
 }
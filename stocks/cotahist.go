@@ -0,0 +1,121 @@
+package stocks
+
+import (
+	"archive/zip"
+	"bufio"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// A DailyPrice is the end-of-day trading summary of a symbol.
+type DailyPrice struct {
+	Symbol  string
+	Date    time.Time
+	Open    float64
+	High    float64
+	Low     float64
+	Average float64
+	Close   float64
+	Trades  int
+	Volume  float64
+}
+
+// COTAHIST market types kept by the parser: cash and odd-lot markets.
+var cotahistMarkets = map[string]bool{"010": true, "020": true}
+
+// ParseCOTAHIST reads the quotes of a B3 COTAHIST historical file. Header
+// and trailer records are skipped, as well as markets other than cash and
+// odd-lot (options, forward, auctions).
+func ParseCOTAHIST(r io.Reader) (prices []DailyPrice, err error) {
+	scanner := bufio.NewScanner(r)
+	line := 0
+	for scanner.Scan() {
+		line++
+		record := scanner.Text()
+		if !strings.HasPrefix(record, "01") {
+			continue
+		}
+		if len(record) < 188 {
+			return nil, fmt.Errorf("COTAHIST line %d: record too short (%d)", line, len(record))
+		}
+		if !cotahistMarkets[record[24:27]] {
+			continue
+		}
+		price, err := parseCotahistRecord(record)
+		if err != nil {
+			return nil, fmt.Errorf("COTAHIST line %d: %v", line, err)
+		}
+		prices = append(prices, price)
+	}
+	return prices, scanner.Err()
+}
+
+func parseCotahistRecord(record string) (p DailyPrice, err error) {
+	if p.Date, err = time.Parse("20060102", record[2:10]); err != nil {
+		return
+	}
+	p.Symbol = strings.TrimSpace(record[12:24])
+	fields := []struct {
+		value *float64
+		start int
+		end   int
+	}{
+		{&p.Open, 56, 69},
+		{&p.High, 69, 82},
+		{&p.Low, 82, 95},
+		{&p.Average, 95, 108},
+		{&p.Close, 108, 121},
+	}
+	for _, f := range fields {
+		var cents int64
+		if cents, err = strconv.ParseInt(record[f.start:f.end], 10, 64); err != nil {
+			return
+		}
+		*f.value = float64(cents) / 100
+	}
+	if p.Trades, err = strconv.Atoi(record[147:152]); err != nil {
+		return
+	}
+	var volume int64
+	if volume, err = strconv.ParseInt(record[170:188], 10, 64); err != nil {
+		return
+	}
+	p.Volume = float64(volume) / 100
+	return
+}
+
+// ReadCOTAHIST parses a COTAHIST file, either plain text or zipped as
+// published by B3.
+func ReadCOTAHIST(path string) ([]DailyPrice, error) {
+	if strings.HasSuffix(strings.ToLower(path), ".zip") {
+		archive, err := zip.OpenReader(path)
+		if err != nil {
+			return nil, err
+		}
+		defer archive.Close()
+		var prices []DailyPrice
+		for _, entry := range archive.File {
+			f, err := entry.Open()
+			if err != nil {
+				return nil, err
+			}
+			entryPrices, err := ParseCOTAHIST(f)
+			f.Close()
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", entry.Name, err)
+			}
+			prices = append(prices, entryPrices...)
+		}
+		return prices, nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return ParseCOTAHIST(f)
+}
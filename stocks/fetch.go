@@ -0,0 +1,179 @@
+package stocks
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Fetch quotes the symbols concurrently using at most workers goroutines.
+// Quotes are returned in the order of the symbols; symbols that failed are
+// left out and their errors combined in err.
+func Fetch(provider QuoteProvider, symbols []string, workers int) (quotes []Quote, err error) {
+	if workers < 1 {
+		workers = 1
+	}
+	results := make([]Quote, len(symbols))
+	errs := make([]error, len(symbols))
+	jobs := make(chan int)
+	var wg sync.WaitGroup
+	for w := 0; w < workers; w++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for i := range jobs {
+				results[i], errs[i] = provider.Quote(symbols[i])
+			}
+		}()
+	}
+	for i := range symbols {
+		jobs <- i
+	}
+	close(jobs)
+	wg.Wait()
+
+	var messages []string
+	for i := range symbols {
+		if errs[i] != nil {
+			messages = append(messages, errs[i].Error())
+			continue
+		}
+		quotes = append(quotes, results[i])
+	}
+	if len(messages) > 0 {
+		err = fmt.Errorf("%s", strings.Join(messages, "\n"))
+	}
+	return
+}
+
+// RateLimited spaces the requests made to a provider by at least Interval.
+type RateLimited struct {
+	Provider QuoteProvider
+	Interval time.Duration
+
+	mu   sync.Mutex
+	next time.Time
+}
+
+// NewRateLimited limits provider to requests per second; zero or less
+// doesn't limit it.
+func NewRateLimited(provider QuoteProvider, requests float64) *RateLimited {
+	r := &RateLimited{Provider: provider}
+	if requests > 0 {
+		r.Interval = time.Duration(float64(time.Second) / requests)
+	}
+	return r
+}
+
+// Name returns the name of the limited provider.
+func (r *RateLimited) Name() string { return r.Provider.Name() }
+
+// Quote waits for the next free slot and calls the limited provider.
+func (r *RateLimited) Quote(symbol string) (Quote, error) {
+	r.mu.Lock()
+	now := time.Now()
+	wait := r.next.Sub(now)
+	if wait < 0 {
+		wait = 0
+	}
+	r.next = now.Add(wait + r.Interval)
+	r.mu.Unlock()
+	time.Sleep(wait)
+	return r.Provider.Quote(symbol)
+}
+
+// A Cache keeps quotes for TTL, in memory and, when Path is set, on disk so
+// they survive between invocations.
+type Cache struct {
+	TTL  time.Duration
+	Path string
+
+	mu      sync.Mutex
+	entries map[string]cacheEntry
+}
+
+type cacheEntry struct {
+	Quote   Quote
+	Fetched time.Time
+}
+
+// NewCache returns a cache loaded from path, which may be empty for an
+// in memory only cache. A missing or unreadable file starts an empty cache.
+func NewCache(ttl time.Duration, path string) *Cache {
+	c := &Cache{TTL: ttl, Path: path, entries: make(map[string]cacheEntry)}
+	if path == "" {
+		return c
+	}
+	if b, err := ioutil.ReadFile(path); err == nil {
+		json.Unmarshal(b, &c.entries)
+	}
+	return c
+}
+
+// Get returns the quote of the symbol if it was fetched within TTL.
+func (c *Cache) Get(symbol string) (Quote, bool) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	e, ok := c.entries[symbol]
+	if !ok || time.Since(e.Fetched) > c.TTL {
+		return Quote{}, false
+	}
+	return e.Quote, true
+}
+
+// Put stores a quote fetched now.
+func (c *Cache) Put(q Quote) {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.entries[q.Symbol] = cacheEntry{Quote: q, Fetched: time.Now()}
+}
+
+// Save writes the fresh entries to the cache file, if any.
+func (c *Cache) Save() error {
+	if c.Path == "" {
+		return nil
+	}
+	c.mu.Lock()
+	fresh := make(map[string]cacheEntry)
+	for symbol, e := range c.entries {
+		if time.Since(e.Fetched) <= c.TTL {
+			fresh[symbol] = e
+		}
+	}
+	b, err := json.Marshal(fresh)
+	c.mu.Unlock()
+	if err != nil {
+		return err
+	}
+	if err = os.MkdirAll(filepath.Dir(c.Path), 0700); err != nil {
+		return err
+	}
+	return ioutil.WriteFile(c.Path, b, 0600)
+}
+
+// Cached answers from the cache and only asks the provider on a miss.
+type Cached struct {
+	Provider QuoteProvider
+	Cache    *Cache
+}
+
+// Name returns the name of the cached provider.
+func (c Cached) Name() string { return c.Provider.Name() }
+
+// Quote returns the cached quote or fetches and caches a new one.
+func (c Cached) Quote(symbol string) (Quote, error) {
+	if q, ok := c.Cache.Get(symbol); ok {
+		return q, nil
+	}
+	q, err := c.Provider.Quote(symbol)
+	if err != nil {
+		return Quote{}, err
+	}
+	c.Cache.Put(q)
+	return q, nil
+}
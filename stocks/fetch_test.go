@@ -0,0 +1,50 @@
+package stocks
+
+import (
+	"testing"
+	"time"
+)
+
+func TestFetch(t *testing.T) {
+	provider := &Fake{Quotes: map[string]Quote{
+		"PETR4": {Symbol: "PETR4", Price: 30},
+		"VALE3": {Symbol: "VALE3", Price: 60},
+	}}
+	quotes, err := Fetch(provider, []string{"VALE3", "XXXX3", "PETR4"}, 2)
+	if err == nil {
+		t.Errorf("Fetch should report the unknown symbol")
+	}
+	if len(quotes) != 2 || quotes[0].Symbol != "VALE3" || quotes[1].Symbol != "PETR4" {
+		t.Errorf("Fetch = %+v, want VALE3 and PETR4 in order", quotes)
+	}
+}
+
+func TestCached(t *testing.T) {
+	provider := &Fake{Quotes: map[string]Quote{"PETR4": {Symbol: "PETR4", Price: 30}}}
+	cached := Cached{Provider: provider, Cache: NewCache(time.Minute, "")}
+	for i := 0; i < 3; i++ {
+		if _, err := cached.Quote("PETR4"); err != nil {
+			t.Fatal(err)
+		}
+	}
+	if provider.Calls != 1 {
+		t.Errorf("provider called %d times, want 1", provider.Calls)
+	}
+}
+
+func TestNewRateLimited(t *testing.T) {
+	tests := []struct {
+		requests float64
+		interval time.Duration
+	}{
+		{1, time.Second},
+		{4, 250 * time.Millisecond},
+		{0, 0},
+		{-1, 0},
+	}
+	for _, tt := range tests {
+		if got := NewRateLimited(&Fake{}, tt.requests).Interval; got != tt.interval {
+			t.Errorf("NewRateLimited(%v).Interval = %v, want %v", tt.requests, got, tt.interval)
+		}
+	}
+}
@@ -0,0 +1,63 @@
+package stocks
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"strings"
+	"time"
+)
+
+// File reads quotes from a local CSV file with the columns symbol, price
+// and, optionally, currency and time (RFC 3339).
+type File struct {
+	Path string
+}
+
+// Name returns "file".
+func (f File) Name() string { return "file" }
+
+// Quote returns the last line of the file for the symbol.
+func (f File) Quote(symbol string) (Quote, error) {
+	file, err := os.Open(f.Path)
+	if err != nil {
+		return Quote{}, err
+	}
+	defer file.Close()
+	reader := csv.NewReader(file)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return Quote{}, fmt.Errorf("Failed to read quotes file %s: %v", f.Path, err)
+	}
+
+	info, _ := file.Stat()
+	found := false
+	var q Quote
+	for _, record := range records {
+		if len(record) < 2 || !strings.EqualFold(strings.TrimSpace(record[0]), symbol) {
+			continue
+		}
+		price, err := parsePrice(record[1])
+		if err != nil {
+			return Quote{}, err
+		}
+		q = Quote{Symbol: symbol, Price: price, Currency: "BRL", Source: f.Name()}
+		if info != nil {
+			q.Time = info.ModTime()
+		}
+		if len(record) > 2 && strings.TrimSpace(record[2]) != "" {
+			q.Currency = strings.TrimSpace(record[2])
+		}
+		if len(record) > 3 {
+			if t, err := time.Parse(time.RFC3339, strings.TrimSpace(record[3])); err == nil {
+				q.Time = t
+			}
+		}
+		found = true
+	}
+	if !found {
+		return Quote{}, fmt.Errorf("No quote for %s in %s", symbol, f.Path)
+	}
+	return q, nil
+}
@@ -0,0 +1,58 @@
+package stocks
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/cookiejar"
+	"strings"
+	"time"
+
+	"github.com/PuerkitoBio/goquery"
+)
+
+var jar, _ = cookiejar.New(nil)
+var defaultClient = &http.Client{
+	Jar: jar,
+}
+
+// Google scrapes the quote of B3 stocks from google search results.
+type Google struct {
+	Client *http.Client
+}
+
+// Name returns "google".
+func (g Google) Name() string { return "google" }
+
+// Quote searches for BVMF:symbol and reads the price from the result card.
+func (g Google) Quote(symbol string) (Quote, error) {
+	client := g.Client
+	if client == nil {
+		client = defaultClient
+	}
+	res, err := client.Get("http://google.com/search?q=BVMF:" + symbol)
+	if err != nil {
+		return Quote{}, err
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK {
+		return Quote{}, fmt.Errorf("status code error: %d %s", res.StatusCode, res.Status)
+	}
+
+	doc, err := goquery.NewDocumentFromReader(res.Body)
+	if err != nil {
+		return Quote{}, err
+	}
+
+	var value string
+	doc.Find("div").Filter(".BNeawe .iBp4i").Each(func(i int, s *goquery.Selection) {
+		value = strings.Split(s.Text(), " ")[0]
+	})
+	if value == "" {
+		return Quote{}, fmt.Errorf("No quote found for %s", symbol)
+	}
+	price, err := parsePrice(value)
+	if err != nil {
+		return Quote{}, err
+	}
+	return Quote{Symbol: symbol, Price: price, Currency: "BRL", Time: time.Now(), Source: g.Name()}, nil
+}
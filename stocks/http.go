@@ -0,0 +1,132 @@
+package stocks
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HTTP fetches quotes from a JSON API. URLTemplate is the address of the
+// quote with {symbol} in place of the symbol, and the fields are dot
+// separated paths to the values in the response, e.g. "results.0.price".
+type HTTP struct {
+	ProviderName  string
+	URLTemplate   string
+	PriceField    string
+	CurrencyField string // Optional, Currency is used when empty
+	TimeField     string // Optional, unix seconds or RFC 3339; now when empty
+	PreviousField string // Optional, previous close price
+	Currency      string
+	Client        *http.Client
+}
+
+// Name returns the configured name or "http".
+func (h HTTP) Name() string {
+	if h.ProviderName == "" {
+		return "http"
+	}
+	return h.ProviderName
+}
+
+// Quote requests the symbol quote and reads its fields from the response.
+func (h HTTP) Quote(symbol string) (Quote, error) {
+	client := h.Client
+	if client == nil {
+		client = defaultClient
+	}
+	uri := strings.Replace(h.URLTemplate, "{symbol}", url.PathEscape(symbol), -1)
+	resp, err := client.Get(uri)
+	if err != nil {
+		return Quote{}, err
+	}
+	defer resp.Body.Close()
+	body, err := ioutil.ReadAll(resp.Body)
+	if err != nil {
+		return Quote{}, fmt.Errorf("Failed to read response body: %v", err)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return Quote{}, fmt.Errorf("http status code: %d\nbody: %s", resp.StatusCode, string(body))
+	}
+	var doc interface{}
+	if err = json.Unmarshal(body, &doc); err != nil {
+		return Quote{}, fmt.Errorf("Failed to unmarshal response body: %s", string(body))
+	}
+
+	q := Quote{Symbol: symbol, Currency: h.Currency, Time: time.Now(), Source: h.Name()}
+	price, err := lookup(doc, h.PriceField)
+	if err != nil {
+		return Quote{}, err
+	}
+	switch p := price.(type) {
+	case float64:
+		q.Price = p
+	case string:
+		if q.Price, err = parsePrice(p); err != nil {
+			return Quote{}, err
+		}
+	default:
+		return Quote{}, fmt.Errorf("Field %s is not a price: %v", h.PriceField, price)
+	}
+	if h.CurrencyField != "" {
+		if currency, err := lookup(doc, h.CurrencyField); err == nil {
+			q.Currency = fmt.Sprint(currency)
+		}
+	}
+	if h.PreviousField != "" {
+		if previous, err := lookup(doc, h.PreviousField); err == nil {
+			switch p := previous.(type) {
+			case float64:
+				q.PreviousClose = p
+			case string:
+				q.PreviousClose, _ = parsePrice(p)
+			}
+		}
+	}
+	if h.TimeField != "" {
+		if t, err := lookup(doc, h.TimeField); err == nil {
+			q.Time = parseTime(t, q.Time)
+		}
+	}
+	return q, nil
+}
+
+// lookup walks a decoded JSON document following a dot separated path.
+func lookup(doc interface{}, path string) (interface{}, error) {
+	current := doc
+	for _, key := range strings.Split(path, ".") {
+		switch node := current.(type) {
+		case map[string]interface{}:
+			value, ok := node[key]
+			if !ok {
+				return nil, fmt.Errorf("Field %s not found", path)
+			}
+			current = value
+		case []interface{}:
+			i, err := strconv.Atoi(key)
+			if err != nil || i < 0 || i >= len(node) {
+				return nil, fmt.Errorf("Field %s not found", path)
+			}
+			current = node[i]
+		default:
+			return nil, fmt.Errorf("Field %s not found", path)
+		}
+	}
+	return current, nil
+}
+
+func parseTime(value interface{}, fallback time.Time) time.Time {
+	switch t := value.(type) {
+	case float64:
+		return time.Unix(int64(t), 0)
+	case string:
+		if parsed, err := time.Parse(time.RFC3339, t); err == nil {
+			return parsed
+		}
+	}
+	return fallback
+}
@@ -0,0 +1,178 @@
+package stocks
+
+import (
+	"encoding/csv"
+	"fmt"
+	"math"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alfredosegundo/magnetis-crawler/fx"
+)
+
+// A Trade is a purchase, positive Quantity, or a sale, negative Quantity.
+type Trade struct {
+	Date     time.Time
+	Symbol   string
+	Quantity float64
+	Price    float64
+	Fees     float64
+	Currency string
+}
+
+// ReadTrades reads a CSV file with the columns date (YYYY-MM-DD), ticker,
+// quantity, price, fees and, optionally, the currency of price and fees,
+// BRL by default. A header line is skipped when present.
+func ReadTrades(path string) (trades []Trade, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = -1
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read trades file %s: %v", path, err)
+	}
+	for i, record := range records {
+		if len(record) != 5 && len(record) != 6 {
+			return nil, fmt.Errorf("Trades file %s line %d: expected 5 or 6 fields, got %d", path, i+1, len(record))
+		}
+		date, err := time.Parse(dateLayout, strings.TrimSpace(record[0]))
+		if err != nil {
+			if i == 0 {
+				continue
+			}
+			return nil, fmt.Errorf("Trades file %s line %d: %v", path, i+1, err)
+		}
+		t := Trade{Date: date, Symbol: strings.ToUpper(strings.TrimSpace(record[1])), Currency: fx.BRL}
+		if len(record) == 6 && strings.TrimSpace(record[5]) != "" {
+			t.Currency = strings.ToUpper(strings.TrimSpace(record[5]))
+		}
+		values := []*float64{&t.Quantity, &t.Price, &t.Fees}
+		for j, v := range values {
+			if *v, err = strconv.ParseFloat(strings.TrimSpace(record[2+j]), 64); err != nil {
+				return nil, fmt.Errorf("Trades file %s line %d: %v", path, i+1, err)
+			}
+		}
+		trades = append(trades, t)
+	}
+	return
+}
+
+// A Position is the quantity held of a symbol at its average acquisition
+// cost, fees included, as required by the brazilian revenue service.
+type Position struct {
+	Symbol      string
+	Quantity    float64
+	AverageCost float64
+	Realized    float64 // Gains realized on sales, net of fees
+	Price       float64 // Current price, zero until valued
+	Currency    string  // Currency of the cost and price
+}
+
+// Cost returns the acquisition cost of the quantity held.
+func (p Position) Cost() float64 { return p.Quantity * p.AverageCost }
+
+// Value returns the market value of the quantity held.
+func (p Position) Value() float64 { return p.Quantity * p.Price }
+
+// Unrealized returns the gain if the position was sold at Price.
+func (p Position) Unrealized() float64 {
+	if p.Price == 0 {
+		return 0
+	}
+	return p.Value() - p.Cost()
+}
+
+// MarketValue returns the value of the position in its currency.
+func (p Position) MarketValue() fx.Money { return fx.Money{Amount: p.Value(), Currency: p.Currency} }
+
+func (p Position) String() string {
+	return fmt.Sprintf("%s\t%.0f\t%.2f\t%.2f\t%.2f\t%.2f\t%s", p.Symbol, p.Quantity, p.AverageCost, p.Price, p.Realized, p.Unrealized(), p.Currency)
+}
+
+// A Month sums the sales and realized gains of a calendar month.
+type Month struct {
+	Month    time.Time // First day of the month
+	Sales    float64   // Gross amount sold
+	Realized float64   // Gains, negative for losses
+}
+
+// Track replays the trades in chronological order and returns the
+// positions by symbol and the monthly sales summaries. Purchases update the
+// average cost; sales realize the difference to the average cost. Only
+// sales in BRL are summarized, foreign stocks follow other tax rules.
+func Track(trades []Trade) (positions []Position, months []Month, err error) {
+	sorted := make([]Trade, len(trades))
+	copy(sorted, trades)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Date.Before(sorted[j].Date) })
+
+	bySymbol := make(map[string]*Position)
+	for _, t := range sorted {
+		p, ok := bySymbol[t.Symbol]
+		if !ok {
+			p = &Position{Symbol: t.Symbol, Currency: t.Currency}
+			bySymbol[t.Symbol] = p
+		}
+		if t.Quantity > 0 {
+			p.AverageCost = (p.Cost() + t.Quantity*t.Price + t.Fees) / (p.Quantity + t.Quantity)
+			p.Quantity += t.Quantity
+			continue
+		}
+		sold := math.Abs(t.Quantity)
+		if sold > p.Quantity+1e-9 {
+			return nil, nil, fmt.Errorf("%s: selling %.0f on %s but only %.0f held", t.Symbol, sold, t.Date.Format(dateLayout), p.Quantity)
+		}
+		gain := sold*t.Price - t.Fees - sold*p.AverageCost
+		p.Quantity -= sold
+		p.Realized += gain
+		if t.Currency != fx.BRL {
+			continue
+		}
+
+		month := time.Date(t.Date.Year(), t.Date.Month(), 1, 0, 0, 0, 0, time.UTC)
+		if len(months) == 0 || !months[len(months)-1].Month.Equal(month) {
+			months = append(months, Month{Month: month})
+		}
+		months[len(months)-1].Sales += sold * t.Price
+		months[len(months)-1].Realized += gain
+	}
+
+	for _, p := range bySymbol {
+		positions = append(positions, *p)
+	}
+	sort.Slice(positions, func(i, j int) bool { return positions[i].Symbol < positions[j].Symbol })
+	return
+}
+
+// Valued sets the price of each position from the quotes of its symbol.
+// Positions keep the currency of their trades, so the average cost and the
+// price are comparable: quotes in another currency are converted with the
+// rates of date.
+func Valued(positions []Position, quotes []Quote, rates *fx.Series, date time.Time) ([]Position, error) {
+	prices := make(map[string]Quote)
+	for _, q := range quotes {
+		prices[q.Symbol] = q
+	}
+	valued := make([]Position, len(positions))
+	for i, p := range positions {
+		if q, ok := prices[p.Symbol]; ok {
+			price := q.Money()
+			if price.Currency == "" {
+				price.Currency = p.Currency
+			}
+			price, err := rates.Convert(price, p.Currency, date)
+			if err != nil {
+				return nil, fmt.Errorf("%s: %v", p.Symbol, err)
+			}
+			p.Price = price.Amount
+		}
+		valued[i] = p
+	}
+	return valued, nil
+}
@@ -0,0 +1,42 @@
+package stocks
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alfredosegundo/magnetis-crawler/fx"
+)
+
+func TestValued(t *testing.T) {
+	date := time.Date(2024, 3, 1, 0, 0, 0, 0, time.UTC)
+	rates := fx.NewSeries(fx.Rate{Date: date, Currency: "USD", Buy: 5, Sell: 5})
+	positions := []Position{
+		{Symbol: "AAPL", Quantity: 1, AverageCost: 9, Currency: "USD"},
+		{Symbol: "PETR4", Quantity: 1, AverageCost: 30, Currency: fx.BRL},
+		{Symbol: "VALE3", Quantity: 1, AverageCost: 60, Currency: fx.BRL},
+	}
+	quotes := []Quote{
+		{Symbol: "AAPL", Price: 50, Currency: fx.BRL},
+		{Symbol: "PETR4", Price: 35},
+	}
+	valued, err := Valued(positions, quotes, rates, date)
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := []struct {
+		price    float64
+		currency string
+	}{
+		{10, "USD"},
+		{35, fx.BRL},
+		{0, fx.BRL},
+	}
+	for i, w := range want {
+		if valued[i].Price != w.price || valued[i].Currency != w.currency {
+			t.Errorf("%s valued at %v %s, want %v %s", valued[i].Symbol, valued[i].Price, valued[i].Currency, w.price, w.currency)
+		}
+	}
+	if _, err = Valued(positions, []Quote{{Symbol: "PETR4", Price: 7, Currency: "EUR"}}, rates, date); err == nil {
+		t.Errorf("Valued should fail without a rate of the quote currency")
+	}
+}
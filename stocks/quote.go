@@ -0,0 +1,127 @@
+// Package stocks fetches stock quotes from pluggable providers.
+package stocks
+
+import (
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alfredosegundo/magnetis-crawler/fx"
+)
+
+// A Quote is the price of a symbol at a given time.
+type Quote struct {
+	Symbol   string
+	Price    float64
+	Currency string
+	Time     time.Time
+	Source   string // Name of the provider that returned the quote
+
+	PreviousClose float64 // Zero when the provider doesn't know it
+}
+
+// Money returns the price of the quote in its currency.
+func (q Quote) Money() fx.Money { return fx.Money{Amount: q.Price, Currency: q.Currency} }
+
+// Change returns the variation since the previous close, zero when unknown.
+func (q Quote) Change() float64 {
+	if q.PreviousClose == 0 {
+		return 0
+	}
+	return q.Price/q.PreviousClose - 1
+}
+
+func (q Quote) String() string {
+	return fmt.Sprintf("%s: %.2f %s", q.Symbol, q.Price, q.Currency)
+}
+
+// A QuoteProvider returns the current quote of a symbol.
+type QuoteProvider interface {
+	Name() string
+	Quote(symbol string) (Quote, error)
+}
+
+// Fallback tries each provider in order and returns the first quote found.
+type Fallback []QuoteProvider
+
+// Name returns the name of every provider of the chain.
+func (f Fallback) Name() string {
+	names := make([]string, len(f))
+	for i, p := range f {
+		names[i] = p.Name()
+	}
+	return strings.Join(names, ",")
+}
+
+// Quote returns the quote of the first provider that succeeds.
+func (f Fallback) Quote(symbol string) (Quote, error) {
+	if len(f) == 0 {
+		return Quote{}, fmt.Errorf("No quote provider configured")
+	}
+	errs := make([]string, 0, len(f))
+	for _, p := range f {
+		q, err := p.Quote(symbol)
+		if err == nil {
+			return q, nil
+		}
+		errs = append(errs, fmt.Sprintf("%s: %v", p.Name(), err))
+	}
+	return Quote{}, fmt.Errorf("Failed to quote %s: %s", symbol, strings.Join(errs, "; "))
+}
+
+// Fake is a provider returning fixed quotes, meant for tests.
+type Fake struct {
+	Quotes map[string]Quote
+	Err    error // Returned for every symbol when set
+	Calls  int   // Number of times Quote was called
+
+	mu sync.Mutex
+}
+
+// Name returns "fake".
+func (f *Fake) Name() string { return "fake" }
+
+// Quote returns the configured quote of the symbol.
+func (f *Fake) Quote(symbol string) (Quote, error) {
+	f.mu.Lock()
+	f.Calls++
+	f.mu.Unlock()
+	if f.Err != nil {
+		return Quote{}, f.Err
+	}
+	q, ok := f.Quotes[symbol]
+	if !ok {
+		return Quote{}, fmt.Errorf("Unknown symbol %s", symbol)
+	}
+	if q.Source == "" {
+		q.Source = f.Name()
+	}
+	return q, nil
+}
+
+// parsePrice accepts prices both in english and brazilian notation. When
+// both separators are used the last one is the decimal separator; a
+// separator repeated is a thousands separator.
+func parsePrice(value string) (float64, error) {
+	value = strings.TrimSpace(value)
+	comma, dot := strings.LastIndex(value, ","), strings.LastIndex(value, ".")
+	switch {
+	case comma >= 0 && dot >= 0 && comma > dot:
+		value = strings.Replace(strings.Replace(value, ".", "", -1), ",", ".", 1)
+	case comma >= 0 && dot >= 0:
+		value = strings.Replace(value, ",", "", -1)
+	case strings.Count(value, ",") > 1:
+		value = strings.Replace(value, ",", "", -1)
+	case comma >= 0:
+		value = strings.Replace(value, ",", ".", 1)
+	case strings.Count(value, ".") > 1:
+		value = strings.Replace(value, ".", "", -1)
+	}
+	var price float64
+	_, err := fmt.Sscanf(value, "%g", &price)
+	if err != nil {
+		return 0, fmt.Errorf("Invalid price %q", value)
+	}
+	return price, nil
+}
@@ -0,0 +1,51 @@
+package stocks
+
+import (
+	"errors"
+	"testing"
+)
+
+func TestParsePrice(t *testing.T) {
+	tests := []struct {
+		value string
+		price float64
+	}{
+		{"12.34", 12.34},
+		{"12,34", 12.34},
+		{" 7 ", 7},
+		{"1,234.56", 1234.56},
+		{"1.234,56", 1234.56},
+		{"1,234,567.89", 1234567.89},
+		{"1.234.567,89", 1234567.89},
+		{"1.234.567", 1234567},
+		{"1,234,567", 1234567},
+	}
+	for _, tt := range tests {
+		price, err := parsePrice(tt.value)
+		if err != nil || price != tt.price {
+			t.Errorf("parsePrice(%q) = %v, %v, want %v", tt.value, price, err, tt.price)
+		}
+	}
+	if _, err := parsePrice("n/a"); err == nil {
+		t.Errorf("parsePrice(%q) should fail", "n/a")
+	}
+}
+
+func TestFallback(t *testing.T) {
+	failing := &Fake{Err: errors.New("down")}
+	working := &Fake{Quotes: map[string]Quote{"PETR4": {Symbol: "PETR4", Price: 30, Currency: "BRL"}}}
+
+	q, err := Fallback{failing, working}.Quote("PETR4")
+	if err != nil || q.Price != 30 || q.Source != "fake" {
+		t.Errorf("Fallback quote = %+v, %v, want PETR4 at 30 from fake", q, err)
+	}
+	if failing.Calls != 1 || working.Calls != 1 {
+		t.Errorf("calls = %d and %d, want 1 each", failing.Calls, working.Calls)
+	}
+	if _, err = (Fallback{failing, working}).Quote("VALE3"); err == nil {
+		t.Errorf("Fallback should fail when no provider knows the symbol")
+	}
+	if _, err = (Fallback{}).Quote("PETR4"); err == nil {
+		t.Errorf("empty Fallback should fail")
+	}
+}
@@ -0,0 +1,160 @@
+package stocks
+
+import (
+	"encoding/csv"
+	"fmt"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+const dateLayout = "2006-01-02"
+
+// A PriceStore keeps end-of-day prices by symbol and date in a local CSV file.
+type PriceStore struct {
+	Path   string
+	prices map[string]map[string]DailyPrice
+}
+
+// DefaultPriceStorePath returns the store file inside the crawler directory.
+func DefaultPriceStorePath() (string, error) {
+	usr, err := user.Current()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(usr.HomeDir, ".magnetis_crawler", "prices.csv"), nil
+}
+
+// OpenPriceStore loads the store file, an absent file is an empty store.
+func OpenPriceStore(path string) (*PriceStore, error) {
+	s := &PriceStore{Path: path, prices: make(map[string]map[string]DailyPrice)}
+	f, err := os.Open(path)
+	if os.IsNotExist(err) {
+		return s, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	records, err := csv.NewReader(f).ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read price store %s: %v", path, err)
+	}
+	for i, record := range records {
+		p, err := decodePrice(record)
+		if err != nil {
+			return nil, fmt.Errorf("Price store %s line %d: %v", path, i+1, err)
+		}
+		s.Add(p)
+	}
+	return s, nil
+}
+
+// Add stores prices, replacing the ones of the same symbol and date.
+func (s *PriceStore) Add(prices ...DailyPrice) {
+	for _, p := range prices {
+		days, ok := s.prices[p.Symbol]
+		if !ok {
+			days = make(map[string]DailyPrice)
+			s.prices[p.Symbol] = days
+		}
+		days[p.Date.Format(dateLayout)] = p
+	}
+}
+
+// Price returns the last price of the symbol on or before date.
+func (s *PriceStore) Price(symbol string, date time.Time) (DailyPrice, bool) {
+	var last DailyPrice
+	found := false
+	for _, p := range s.prices[symbol] {
+		if !p.Date.After(date) && (!found || p.Date.After(last.Date)) {
+			last = p
+			found = true
+		}
+	}
+	return last, found
+}
+
+// Save writes the store file.
+func (s *PriceStore) Save() error {
+	if err := os.MkdirAll(filepath.Dir(s.Path), 0700); err != nil {
+		return err
+	}
+	f, err := os.OpenFile(s.Path, os.O_RDWR|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+	symbols := make([]string, 0, len(s.prices))
+	for symbol := range s.prices {
+		symbols = append(symbols, symbol)
+	}
+	sort.Strings(symbols)
+	w := csv.NewWriter(f)
+	for _, symbol := range symbols {
+		days := make([]string, 0, len(s.prices[symbol]))
+		for day := range s.prices[symbol] {
+			days = append(days, day)
+		}
+		sort.Strings(days)
+		for _, day := range days {
+			w.Write(encodePrice(s.prices[symbol][day]))
+		}
+	}
+	w.Flush()
+	return w.Error()
+}
+
+// At returns a provider quoting the closing prices of the given date.
+func (s *PriceStore) At(date time.Time) QuoteProvider {
+	return storeProvider{store: s, date: date}
+}
+
+type storeProvider struct {
+	store *PriceStore
+	date  time.Time
+}
+
+func (p storeProvider) Name() string { return "cotahist" }
+
+func (p storeProvider) Quote(symbol string) (Quote, error) {
+	price, ok := p.store.Price(symbol, p.date)
+	if !ok {
+		return Quote{}, fmt.Errorf("No price for %s on %s", symbol, p.date.Format(dateLayout))
+	}
+	q := Quote{Symbol: symbol, Price: price.Close, Currency: "BRL", Time: price.Date, Source: p.Name()}
+	if previous, ok := p.store.Price(symbol, price.Date.AddDate(0, 0, -1)); ok {
+		q.PreviousClose = previous.Close
+	}
+	return q, nil
+}
+
+func encodePrice(p DailyPrice) []string {
+	format := func(v float64) string { return strconv.FormatFloat(v, 'f', 2, 64) }
+	return []string{p.Symbol, p.Date.Format(dateLayout), format(p.Open), format(p.High), format(p.Low),
+		format(p.Average), format(p.Close), strconv.Itoa(p.Trades), format(p.Volume)}
+}
+
+func decodePrice(record []string) (p DailyPrice, err error) {
+	if len(record) != 9 {
+		return p, fmt.Errorf("expected 9 fields, got %d", len(record))
+	}
+	p.Symbol = record[0]
+	if p.Date, err = time.Parse(dateLayout, record[1]); err != nil {
+		return
+	}
+	values := []*float64{&p.Open, &p.High, &p.Low, &p.Average, &p.Close}
+	for i, v := range values {
+		if *v, err = strconv.ParseFloat(record[2+i], 64); err != nil {
+			return
+		}
+	}
+	if p.Trades, err = strconv.Atoi(record[7]); err != nil {
+		return
+	}
+	p.Volume, err = strconv.ParseFloat(record[8], 64)
+	return
+}
@@ -0,0 +1,26 @@
+package stocks
+
+import (
+	"bufio"
+	"os"
+	"strings"
+)
+
+// ReadWatchlist reads the stock codes of a config file, one per line.
+// Blank lines and lines starting with # are ignored.
+func ReadWatchlist(path string) (codes []string, err error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		codes = append(codes, strings.ToUpper(line))
+	}
+	return codes, scanner.Err()
+}
@@ -0,0 +1,250 @@
+// Package store keeps the crawled data on local JSON files, so it can be
+// served and exported without signing in to magnetis again.
+package store
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"os/user"
+	"path/filepath"
+	"sync"
+	"time"
+
+	"github.com/alfredosegundo/magnetis-crawler/magnetis"
+	"github.com/alfredosegundo/magnetis-crawler/stocks"
+)
+
+// Names of the stored files, one for each kind of data
+const (
+	EquitiesFile     = "equities.json"
+	ApplicationsFile = "applications.json"
+	AssetsFile       = "assets.json"
+	PlanFile         = "plan.json"
+	QuotesFile       = "quotes.json"
+)
+
+// A Store is a directory with the last crawled data.
+type Store struct {
+	Dir string
+
+	mu sync.Mutex // Serializes the health updates
+}
+
+// DefaultDir returns ~/.magnetis_crawler/data.
+func DefaultDir() (string, error) {
+	usr, err := user.Current()
+	if err != nil {
+		return "", err
+	}
+	return filepath.Join(usr.HomeDir, ".magnetis_crawler", "data"), nil
+}
+
+// Open creates the store directory if needed. An empty dir opens the
+// default directory.
+func Open(dir string) (*Store, error) {
+	if dir == "" {
+		var err error
+		if dir, err = DefaultDir(); err != nil {
+			return nil, err
+		}
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, err
+	}
+	return &Store{Dir: dir}, nil
+}
+
+// SaveEquities replaces the stored equity curve.
+func (s *Store) SaveEquities(equities []magnetis.Equity) error {
+	return s.save(EquitiesFile, equities)
+}
+
+// Equities returns the stored equity curve, empty when never saved.
+func (s *Store) Equities() (equities []magnetis.Equity, err error) {
+	err = s.load(EquitiesFile, &equities)
+	return equities, err
+}
+
+// SaveApplications replaces the stored applications.
+func (s *Store) SaveApplications(applications []magnetis.Application) error {
+	return s.save(ApplicationsFile, applications)
+}
+
+// MergeApplications merges a fresh scrape into the stored applications,
+// keeping the history magnetis no longer lists, and tells what changed.
+func (s *Store) MergeApplications(fresh []magnetis.Application) (magnetis.Diff, error) {
+	stored, err := s.Applications()
+	if err != nil {
+		return magnetis.Diff{}, err
+	}
+	merged, diff, err := magnetis.Merge(stored, fresh)
+	if err != nil {
+		return diff, err
+	}
+	return diff, s.SaveApplications(merged)
+}
+
+// Applications returns the stored applications, empty when never saved.
+func (s *Store) Applications() (applications []magnetis.Application, err error) {
+	err = s.load(ApplicationsFile, &applications)
+	return applications, err
+}
+
+// SaveAssets replaces the stored assets.
+func (s *Store) SaveAssets(assets []magnetis.Asset) error {
+	return s.save(AssetsFile, assets)
+}
+
+// Assets returns the stored assets, empty when never saved.
+func (s *Store) Assets() (assets []magnetis.Asset, err error) {
+	err = s.load(AssetsFile, &assets)
+	return assets, err
+}
+
+// SavePlan replaces the stored investment plan.
+func (s *Store) SavePlan(plan *magnetis.InvestmentPlan) error {
+	return s.save(PlanFile, plan)
+}
+
+// Plan returns the stored investment plan, nil when never saved.
+func (s *Store) Plan() (*magnetis.InvestmentPlan, error) {
+	var plan *magnetis.InvestmentPlan
+	err := s.load(PlanFile, &plan)
+	return plan, err
+}
+
+// SaveQuotes merges the quotes with the stored ones, keeping the last
+// quote of each symbol.
+func (s *Store) SaveQuotes(quotes []stocks.Quote) error {
+	stored, err := s.Quotes()
+	if err != nil {
+		return err
+	}
+	bySymbol := make(map[string]int, len(stored))
+	for i, q := range stored {
+		bySymbol[q.Symbol] = i
+	}
+	for _, q := range quotes {
+		if i, ok := bySymbol[q.Symbol]; ok {
+			stored[i] = q
+			continue
+		}
+		bySymbol[q.Symbol] = len(stored)
+		stored = append(stored, q)
+	}
+	return s.save(QuotesFile, stored)
+}
+
+// Quotes returns the stored quotes, empty when never saved.
+func (s *Store) Quotes() (quotes []stocks.Quote, err error) {
+	err = s.load(QuotesFile, &quotes)
+	return quotes, err
+}
+
+// UpdatedAt tells when the file was last saved, zero when never saved.
+func (s *Store) UpdatedAt(name string) time.Time {
+	info, err := os.Stat(filepath.Join(s.Dir, name))
+	if err != nil {
+		return time.Time{}
+	}
+	return info.ModTime()
+}
+
+func (s *Store) save(name string, v interface{}) error {
+	b, err := json.MarshalIndent(v, "", "  ")
+	if err != nil {
+		return err
+	}
+	// Each save writes its own temporary file, as other processes may save
+	// the same file at the same time
+	f, err := ioutil.TempFile(s.Dir, name+".*")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+	_, err = f.Write(b)
+	if closeErr := f.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		return err
+	}
+	return os.Rename(f.Name(), filepath.Join(s.Dir, name))
+}
+
+func (s *Store) load(name string, v interface{}) error {
+	b, err := ioutil.ReadFile(filepath.Join(s.Dir, name))
+	if os.IsNotExist(err) {
+		return nil
+	}
+	if err != nil {
+		return err
+	}
+	if err = json.Unmarshal(b, v); err != nil {
+		return fmt.Errorf("Failed to read %s: %v", name, err)
+	}
+	return nil
+}
+
+// HealthFile is the name of the crawler health file
+const HealthFile = "health.json"
+
+// JobHealth counts the runs of a crawler job.
+type JobHealth struct {
+	Runs        int       `json:"runs"`
+	Failures    int       `json:"failures"`
+	Duration    float64   `json:"duration_seconds"` // Of the last run
+	LastSuccess time.Time `json:"last_success"`
+}
+
+// Health counts the crawler sign ins and job runs.
+type Health struct {
+	SigninFailures int                  `json:"signin_failures"`
+	Jobs           map[string]JobHealth `json:"jobs"`
+}
+
+// Health returns the crawler health counters.
+func (s *Store) Health() (Health, error) {
+	var h Health
+	err := s.load(HealthFile, &h)
+	if h.Jobs == nil {
+		h.Jobs = make(map[string]JobHealth)
+	}
+	return h, err
+}
+
+// RecordSignin counts a failed sign in, nothing is recorded on success.
+func (s *Store) RecordSignin(err error) error {
+	if err == nil {
+		return nil
+	}
+	return s.updateHealth(func(h *Health) { h.SigninFailures++ })
+}
+
+// RecordJob counts a run of the job.
+func (s *Store) RecordJob(name string, duration time.Duration, err error) error {
+	return s.updateHealth(func(h *Health) {
+		j := h.Jobs[name]
+		j.Runs++
+		j.Duration = duration.Seconds()
+		if err != nil {
+			j.Failures++
+		} else {
+			j.LastSuccess = time.Now()
+		}
+		h.Jobs[name] = j
+	})
+}
+
+func (s *Store) updateHealth(change func(*Health)) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	h, err := s.Health()
+	if err != nil {
+		return err
+	}
+	change(&h)
+	return s.save(HealthFile, h)
+}
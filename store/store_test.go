@@ -0,0 +1,155 @@
+package store
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sync"
+	"testing"
+	"time"
+
+	"github.com/alfredosegundo/magnetis-crawler/magnetis"
+	"github.com/alfredosegundo/magnetis-crawler/stocks"
+)
+
+func open(t *testing.T) (*Store, func()) {
+	dir, err := ioutil.TempDir("", "store")
+	if err != nil {
+		t.Fatal(err)
+	}
+	st, err := Open(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return st, func() { os.RemoveAll(dir) }
+}
+
+func purchase(day int, investment string) magnetis.Application {
+	date := time.Date(2024, 1, day, 0, 0, 0, 0, time.UTC)
+	return magnetis.Application{Date: date, ApplicationDate: date, Type: magnetis.MoneyApplication, Investment: investment, Quantity: 1, Price: 100, Net: 100}
+}
+
+func TestMergeApplications(t *testing.T) {
+	st, remove := open(t)
+	defer remove()
+
+	steps := []struct {
+		fresh  []magnetis.Application
+		stored []string // Investments after the step
+		diff   string
+		err    bool
+	}{
+		{[]magnetis.Application{purchase(2, "LCI"), purchase(1, "CDB")}, []string{"LCI", "CDB"}, "2 added, 0 removed, 0 changed", false},
+		{[]magnetis.Application{purchase(3, "LCA"), purchase(2, "LCI")}, []string{"LCA", "LCI", "CDB"}, "1 added, 0 removed, 0 changed", false},
+		{nil, []string{"LCA", "LCI", "CDB"}, "", true},
+	}
+	for i, step := range steps {
+		diff, err := st.MergeApplications(step.fresh)
+		if (err != nil) != step.err {
+			t.Fatalf("step %d: MergeApplications error = %v", i, err)
+		}
+		if err == nil && diff.String() != step.diff {
+			t.Errorf("step %d: diff %s, want %s", i, diff, step.diff)
+		}
+		stored, err := st.Applications()
+		if err != nil {
+			t.Fatal(err)
+		}
+		var got []string
+		for _, a := range stored {
+			if a.ID == "" {
+				t.Errorf("step %d: stored %s without ID", i, a.Investment)
+			}
+			got = append(got, a.Investment)
+		}
+		if len(got) != len(step.stored) {
+			t.Fatalf("step %d: stored %v, want %v", i, got, step.stored)
+		}
+		for j := range got {
+			if got[j] != step.stored[j] {
+				t.Errorf("step %d: stored %v, want %v", i, got, step.stored)
+				break
+			}
+		}
+	}
+}
+
+func TestSaveQuotes(t *testing.T) {
+	st, remove := open(t)
+	defer remove()
+	at := time.Date(2024, 3, 1, 17, 0, 0, 0, time.UTC)
+	if err := st.SaveQuotes([]stocks.Quote{{Symbol: "PETR4", Price: 38, Time: at}, {Symbol: "VALE3", Price: 61, Time: at}}); err != nil {
+		t.Fatal(err)
+	}
+	if err := st.SaveQuotes([]stocks.Quote{{Symbol: "PETR4", Price: 39, Currency: "BRL", Time: at.Add(time.Hour)}}); err != nil {
+		t.Fatal(err)
+	}
+	quotes, err := st.Quotes()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(quotes) != 2 || quotes[0].Symbol != "PETR4" || quotes[0].Price != 39 || !quotes[0].Time.Equal(at.Add(time.Hour)) || quotes[1].Price != 61 {
+		t.Errorf("Quotes = %+v, want the last PETR4 quote and VALE3", quotes)
+	}
+}
+
+func TestRecordJob(t *testing.T) {
+	st, remove := open(t)
+	defer remove()
+	before := time.Now()
+	records := []error{
+		st.RecordJob("curve", time.Second, nil),
+		st.RecordJob("curve", 3*time.Second, errors.New("timeout")),
+		st.RecordSignin(errors.New("bad password")),
+		st.RecordSignin(nil),
+	}
+	for _, err := range records {
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	// Read back from another store on the same directory
+	other, err := Open(st.Dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	h, err := other.Health()
+	if err != nil {
+		t.Fatal(err)
+	}
+	j := h.Jobs["curve"]
+	if h.SigninFailures != 1 || j.Runs != 2 || j.Failures != 1 || j.Duration != 3 || j.LastSuccess.Before(before) {
+		t.Errorf("Health = %+v, want 1 sign in failure and 2 runs of curve, the last one failed", h)
+	}
+}
+
+func TestConcurrentSaves(t *testing.T) {
+	st, remove := open(t)
+	defer remove()
+	var wg sync.WaitGroup
+	for i := 0; i < 8; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			// Each goroutine opens its own store, like separate processes
+			s, err := Open(st.Dir)
+			if err != nil {
+				t.Error(err)
+				return
+			}
+			equities := make([]magnetis.Equity, 1000+i)
+			if err := s.SaveEquities(equities); err != nil {
+				t.Error(err)
+			}
+		}(i)
+	}
+	wg.Wait()
+	if _, err := st.Equities(); err != nil {
+		t.Errorf("Equities after concurrent saves: %v", err)
+	}
+	files, err := filepath.Glob(filepath.Join(st.Dir, EquitiesFile+".*"))
+	if err != nil || len(files) != 0 {
+		t.Errorf("temporary files left behind: %v %v", files, err)
+	}
+}
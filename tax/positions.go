@@ -0,0 +1,221 @@
+package tax
+
+import (
+	"fmt"
+	"math"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alfredosegundo/magnetis-crawler/magnetis"
+)
+
+// A Lot is a single application still held in an investment.
+type Lot struct {
+	Date     time.Time
+	Quantity float64
+	Price    float64
+}
+
+// Cost returns the amount applied on the lot.
+func (l Lot) Cost() float64 { return l.Quantity * l.Price }
+
+// Lots replays the applications in chronological order and returns, for
+// each investment, the lots that were not redeemed yet. Redemptions and
+// expired titles consume the oldest lots first.
+func Lots(applications []magnetis.Application) map[string][]Lot {
+	lots, _ := replay(applications)
+	return lots
+}
+
+// A Realized is a redemption matched against the lots it consumed.
+type Realized struct {
+	Date       time.Time
+	Investment string
+	Gross      float64
+	Invested   float64
+	Reported   float64 // IR scraped from the applications history
+	Expected   float64 // IR computed from the regressive table
+}
+
+// Gain returns the gross gain of the redemption.
+func (r Realized) Gain() float64 { return r.Gross - r.Invested }
+
+// Realizations returns every redemption of the history with its cost and IR.
+// IR withdrawals on the same day and investment of a redemption are counted
+// as part of that redemption's reported IR.
+func Realizations(applications []magnetis.Application) []Realized {
+	_, realized := replay(applications)
+	return realized
+}
+
+func replay(applications []magnetis.Application) (lots map[string][]Lot, realized []Realized) {
+	sorted := chronological(applications)
+	withheld := make(map[string]float64)
+	for _, a := range sorted {
+		if a.Type == magnetis.IRWithdrawal {
+			withheld[redemptionKey(a)] += math.Abs(a.IR)
+		}
+	}
+	lots = make(map[string][]Lot)
+	for _, a := range sorted {
+		name := strings.TrimSpace(a.Investment)
+		switch a.Type {
+		case magnetis.MoneyApplication:
+			lots[name] = append(lots[name], Lot{Date: a.Date, Quantity: a.Quantity, Price: a.Price})
+		case magnetis.Redemption, magnetis.ExpiredTitle:
+			var consumed []Lot
+			lots[name], consumed = consume(lots[name], math.Abs(a.Quantity))
+			if len(consumed) == 0 {
+				continue
+			}
+			r := Realized{Date: a.Date, Investment: name, Gross: math.Abs(a.Quantity * a.Price)}
+			var quantity float64
+			for _, lot := range consumed {
+				quantity += lot.Quantity
+			}
+			for _, lot := range consumed {
+				t := Calculate(Redemption{Applied: lot.Date, Redeemed: a.Date, Invested: lot.Cost(), Gross: r.Gross * lot.Quantity / quantity, Instrument: name})
+				r.Invested += lot.Cost()
+				r.Expected += t.IR
+			}
+			r.Expected = round(r.Expected)
+			key := redemptionKey(a)
+			r.Reported = math.Abs(a.IR) + withheld[key]
+			withheld[key] = 0
+			realized = append(realized, r)
+		}
+	}
+	return
+}
+
+// consume removes quantity from the oldest lots and returns the remaining
+// lots and the consumed ones.
+func consume(lots []Lot, quantity float64) (remaining []Lot, consumed []Lot) {
+	for _, lot := range lots {
+		if quantity <= 0 {
+			remaining = append(remaining, lot)
+			continue
+		}
+		taken := math.Min(quantity, lot.Quantity)
+		consumed = append(consumed, Lot{Date: lot.Date, Quantity: taken, Price: lot.Price})
+		quantity -= taken
+		if lot.Quantity > taken {
+			lot.Quantity -= taken
+			remaining = append(remaining, lot)
+		}
+	}
+	return
+}
+
+func chronological(applications []magnetis.Application) []magnetis.Application {
+	sorted := make([]magnetis.Application, len(applications))
+	copy(sorted, applications)
+	sort.SliceStable(sorted, func(i, j int) bool { return sorted[i].Date.Before(sorted[j].Date) })
+	return sorted
+}
+
+// An Estimate holds the taxes due if an asset was liquidated on a given day.
+type Estimate struct {
+	Asset         magnetis.Asset
+	Gross         float64
+	Invested      float64
+	IOF           float64
+	IR            float64
+	Net           float64
+	Exempt        bool
+	Approximated  bool      // No lots were found, IR uses the highest rate
+	NextComeCotas time.Time // Zero unless the asset is a fund
+	ComeCotas     float64   // Projected come-cotas on the current gain
+}
+
+func (e Estimate) String() string {
+	return fmt.Sprintf("%s\t%.2f\t%.2f\t%.2f\t%.2f\t%.2f", e.Asset.Name, e.Gross, e.Invested, e.IOF, e.IR, e.Net)
+}
+
+// EstimateLiquidation estimates the taxes due on each asset if all of them
+// were redeemed at the given time. The holding period of each asset is
+// taken from the lots found on the applications history; when the asset
+// can't be matched the gain is taken from the asset return and taxed with
+// the highest IR rate.
+func EstimateLiquidation(assets []magnetis.Asset, applications []magnetis.Application, now time.Time) []Estimate {
+	lots := Lots(applications)
+	estimates := make([]Estimate, 0, len(assets))
+	for _, asset := range assets {
+		e := Estimate{Asset: asset, Gross: parseAmount(asset.Amount)}
+		instrument := Instrument(asset.InstrumentTypeName, asset.Name)
+		e.Exempt = Exempt(instrument)
+		assetLots := lots[strings.TrimSpace(asset.Name)]
+		var cost float64
+		for _, lot := range assetLots {
+			cost += lot.Cost()
+		}
+		if cost > 0 {
+			e.Invested = cost
+			for _, lot := range assetLots {
+				t := Calculate(Redemption{
+					Applied:    lot.Date,
+					Redeemed:   now,
+					Invested:   lot.Cost(),
+					Gross:      e.Gross * lot.Cost() / cost,
+					Instrument: instrument,
+				})
+				e.IOF += t.IOF
+				e.IR += t.IR
+			}
+		} else {
+			// Without lots the holding period is unknown: IOF is left out and
+			// the gain is taxed with the rate of the shortest period.
+			e.Approximated = true
+			e.Invested = e.Gross - parseAmount(asset.AssetReturn)
+			if gain := e.Gross - e.Invested; gain > 0 && !e.Exempt {
+				e.IR = round(gain * IRRate(0))
+			}
+		}
+		e.Net = e.Gross - e.IOF - e.IR
+		if Fund(instrument) {
+			e.NextComeCotas = NextComeCotas(now)
+			e.ComeCotas = ComeCotas(e.Gross-e.Invested, false)
+		}
+		estimates = append(estimates, e)
+	}
+	return estimates
+}
+
+// A Discrepancy is a redemption whose IR scraped from the applications
+// history doesn't match the IR computed from the regressive table.
+type Discrepancy struct {
+	Date       time.Time
+	Investment string
+	Gross      float64
+	Invested   float64
+	Reported   float64
+	Expected   float64
+}
+
+func (d Discrepancy) String() string {
+	return fmt.Sprintf("%v\t%s\treported %.2f\texpected %.2f", d.Date, d.Investment, d.Reported, d.Expected)
+}
+
+// Verify recomputes the IR of every redemption in the applications history
+// and returns the ones that differ from the reported value by more than
+// tolerance.
+func Verify(applications []magnetis.Application, tolerance float64) (discrepancies []Discrepancy) {
+	for _, r := range Realizations(applications) {
+		if math.Abs(r.Reported-r.Expected) > tolerance {
+			discrepancies = append(discrepancies, Discrepancy{
+				Date:       r.Date,
+				Investment: r.Investment,
+				Gross:      r.Gross,
+				Invested:   r.Invested,
+				Reported:   r.Reported,
+				Expected:   r.Expected,
+			})
+		}
+	}
+	return
+}
+
+func redemptionKey(a magnetis.Application) string {
+	return a.Date.Format("2006-01-02") + "|" + strings.TrimSpace(a.Investment)
+}
@@ -0,0 +1,255 @@
+package tax
+
+import (
+	"encoding/csv"
+	"fmt"
+	"html/template"
+	"io"
+	"math"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/alfredosegundo/magnetis-crawler/magnetis"
+)
+
+// An Entry holds what an investment contributes to the annual tax report:
+// its balance at cost on the end of the previous and of the reported year
+// ("Bens e Direitos") and the income realized during the year.
+type Entry struct {
+	Investment      string
+	Issuer          string
+	CNPJ            string
+	PreviousBalance float64
+	Balance         float64
+	ExemptIncome    float64 // Rendimentos isentos e não tributáveis
+	TaxedIncome     float64 // Rendimentos sujeitos à tributação exclusiva, net of IR
+	WithheldIR      float64
+}
+
+// A Withholding sums the IR withheld by an issuer during the year.
+type Withholding struct {
+	Issuer string
+	CNPJ   string
+	IR     float64
+}
+
+// A Report is the data needed to fill the IRPF declaration for a year.
+type Report struct {
+	Year         int
+	Entries      []Entry
+	Withholdings []Withholding
+	ExemptIncome float64
+	TaxedIncome  float64
+	WithheldIR   float64
+}
+
+// UnknownIssuer is reported for investments whose issuer can't be found,
+// usually the ones already redeemed
+const UnknownIssuer = "Desconhecido"
+
+// AnnualReport builds the tax report of a year from the applications
+// history. Assets are used to find the issuer and instrument type of each
+// investment, and cnpjs maps issuer names to their CNPJ. The issuer of an
+// investment no longer held is the issuer of cnpjs found on its name.
+func AnnualReport(year int, applications []magnetis.Application, assets []magnetis.Asset, cnpjs map[string]string) *Report {
+	issuers := make(map[string]string)
+	types := make(map[string]string)
+	for _, asset := range assets {
+		issuers[strings.TrimSpace(asset.Name)] = asset.Issuer
+		types[strings.TrimSpace(asset.Name)] = asset.InstrumentTypeName
+	}
+	entries := make(map[string]*Entry)
+	entry := func(investment string) *Entry {
+		if e, ok := entries[investment]; ok {
+			return e
+		}
+		issuer := issuerOf(investment, issuers, cnpjs)
+		e := &Entry{Investment: investment, Issuer: issuer, CNPJ: cnpjs[issuer]}
+		entries[investment] = e
+		return e
+	}
+
+	endOfPrevious := time.Date(year, time.January, 1, 0, 0, 0, 0, time.UTC)
+	endOfYear := time.Date(year+1, time.January, 1, 0, 0, 0, 0, time.UTC)
+	for investment, lots := range Lots(until(applications, endOfPrevious)) {
+		entry(investment).PreviousBalance = cost(lots)
+	}
+	for investment, lots := range Lots(until(applications, endOfYear)) {
+		entry(investment).Balance = cost(lots)
+	}
+
+	r := &Report{Year: year}
+	withholdings := make(map[string]*Withholding)
+	withhold := func(e *Entry, ir float64) {
+		if ir == 0 {
+			return
+		}
+		e.WithheldIR += ir
+		r.WithheldIR += ir
+		w, ok := withholdings[e.Issuer]
+		if !ok {
+			w = &Withholding{Issuer: e.Issuer, CNPJ: e.CNPJ}
+			withholdings[e.Issuer] = w
+		}
+		w.IR += ir
+	}
+	matched := make(map[string]bool)
+	for _, realized := range Realizations(until(applications, endOfYear)) {
+		if realized.Date.Before(endOfPrevious) {
+			continue
+		}
+		matched[realized.Date.Format("2006-01-02")+"|"+realized.Investment] = true
+		e := entry(realized.Investment)
+		if Exempt(Instrument(types[realized.Investment], realized.Investment)) {
+			e.ExemptIncome += realized.Gain()
+			r.ExemptIncome += realized.Gain()
+		} else {
+			e.TaxedIncome += realized.Gain() - realized.Reported
+			r.TaxedIncome += realized.Gain() - realized.Reported
+		}
+		withhold(e, realized.Reported)
+	}
+	// IR withheld on redemptions that couldn't be matched to their lots
+	for _, a := range until(applications, endOfYear) {
+		if a.Date.Before(endOfPrevious) || matched[redemptionKey(a)] {
+			continue
+		}
+		switch a.Type {
+		case magnetis.IRWithdrawal, magnetis.Redemption, magnetis.ExpiredTitle:
+			if ir := math.Abs(a.IR); ir > 0 {
+				withhold(entry(strings.TrimSpace(a.Investment)), ir)
+			}
+		}
+	}
+
+	for _, e := range entries {
+		if e.PreviousBalance == 0 && e.Balance == 0 && e.ExemptIncome == 0 && e.TaxedIncome == 0 && e.WithheldIR == 0 {
+			continue
+		}
+		r.Entries = append(r.Entries, *e)
+	}
+	sort.Slice(r.Entries, func(i, j int) bool { return r.Entries[i].Investment < r.Entries[j].Investment })
+	for _, w := range withholdings {
+		r.Withholdings = append(r.Withholdings, *w)
+	}
+	sort.Slice(r.Withholdings, func(i, j int) bool { return r.Withholdings[i].Issuer < r.Withholdings[j].Issuer })
+	return r
+}
+
+func issuerOf(investment string, issuers map[string]string, cnpjs map[string]string) string {
+	if issuer := issuers[investment]; issuer != "" {
+		return issuer
+	}
+	upper := strings.ToUpper(investment)
+	var found string
+	for issuer := range cnpjs {
+		if len(issuer) > len(found) && strings.Contains(upper, strings.ToUpper(issuer)) {
+			found = issuer
+		}
+	}
+	if found == "" {
+		return UnknownIssuer
+	}
+	return found
+}
+
+func until(applications []magnetis.Application, end time.Time) (filtered []magnetis.Application) {
+	for _, a := range applications {
+		if a.Date.Before(end) {
+			filtered = append(filtered, a)
+		}
+	}
+	return
+}
+
+func cost(lots []Lot) (total float64) {
+	for _, lot := range lots {
+		total += lot.Cost()
+	}
+	return round(total)
+}
+
+// ReadCNPJs reads a CSV file where each line holds an issuer name and its CNPJ.
+func ReadCNPJs(file string) (cnpjs map[string]string, err error) {
+	f, err := os.Open(file)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	reader := csv.NewReader(f)
+	reader.FieldsPerRecord = 2
+	records, err := reader.ReadAll()
+	if err != nil {
+		return nil, fmt.Errorf("Failed to read CNPJ file %s: %v", file, err)
+	}
+	cnpjs = make(map[string]string)
+	for _, record := range records {
+		cnpjs[strings.TrimSpace(record[0])] = strings.TrimSpace(record[1])
+	}
+	return
+}
+
+// PreviousYear returns the year before the reported one.
+func (r *Report) PreviousYear() int { return r.Year - 1 }
+
+// WriteCSV writes the report entries as CSV, one investment per line.
+func (r *Report) WriteCSV(w io.Writer) error {
+	out := csv.NewWriter(w)
+	out.Write([]string{"Investimento", "Emissor", "CNPJ",
+		fmt.Sprintf("Situação em 31/12/%d", r.PreviousYear()), fmt.Sprintf("Situação em 31/12/%d", r.Year),
+		"Rendimentos isentos", "Rendimentos tributação exclusiva", "IR retido"})
+	for _, e := range r.Entries {
+		out.Write([]string{e.Investment, e.Issuer, e.CNPJ,
+			money(e.PreviousBalance), money(e.Balance),
+			money(e.ExemptIncome), money(e.TaxedIncome), money(e.WithheldIR)})
+	}
+	out.Write([]string{"Total", "", "", "", "", money(r.ExemptIncome), money(r.TaxedIncome), money(r.WithheldIR)})
+	out.Flush()
+	return out.Error()
+}
+
+// WriteHTML writes the report as a printable HTML page.
+func (r *Report) WriteHTML(w io.Writer) error {
+	return reportTemplate.Execute(w, r)
+}
+
+func money(value float64) string {
+	return fmt.Sprintf("%.2f", value)
+}
+
+var reportTemplate = template.Must(template.New("report").Funcs(template.FuncMap{"money": money}).Parse(`<!DOCTYPE html>
+<html lang="pt-BR">
+<head>
+<meta charset="utf-8">
+<title>Declaração de IRPF {{.Year}}</title>
+<style>
+body { font-family: sans-serif; margin: 2em; }
+table { border-collapse: collapse; width: 100%; margin-bottom: 2em; }
+th, td { border: 1px solid #999; padding: 4px 8px; }
+td.value { text-align: right; }
+@media print { body { margin: 0; } }
+</style>
+</head>
+<body>
+<h1>Declaração de IRPF - ano-calendário {{.Year}}</h1>
+<h2>Bens e Direitos</h2>
+<table>
+<tr><th>Investimento</th><th>Emissor</th><th>CNPJ</th><th>Situação em 31/12/{{.PreviousYear}}</th><th>Situação em 31/12/{{.Year}}</th></tr>
+{{range .Entries}}<tr><td>{{.Investment}}</td><td>{{.Issuer}}</td><td>{{.CNPJ}}</td><td class="value">{{money .PreviousBalance}}</td><td class="value">{{money .Balance}}</td></tr>
+{{end}}</table>
+<h2>Rendimentos</h2>
+<table>
+<tr><th>Investimento</th><th>Isentos e não tributáveis</th><th>Tributação exclusiva</th><th>IR retido</th></tr>
+{{range .Entries}}{{if or .ExemptIncome .TaxedIncome}}<tr><td>{{.Investment}}</td><td class="value">{{money .ExemptIncome}}</td><td class="value">{{money .TaxedIncome}}</td><td class="value">{{money .WithheldIR}}</td></tr>
+{{end}}{{end}}<tr><th>Total</th><th class="value">{{money .ExemptIncome}}</th><th class="value">{{money .TaxedIncome}}</th><th class="value">{{money .WithheldIR}}</th></tr>
+</table>
+<h2>IR retido por fonte pagadora</h2>
+<table>
+<tr><th>Emissor</th><th>CNPJ</th><th>IR retido</th></tr>
+{{range .Withholdings}}<tr><td>{{.Issuer}}</td><td>{{.CNPJ}}</td><td class="value">{{money .IR}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
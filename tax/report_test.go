@@ -0,0 +1,43 @@
+package tax
+
+import (
+	"testing"
+	"time"
+
+	"github.com/alfredosegundo/magnetis-crawler/magnetis"
+)
+
+func TestAnnualReport(t *testing.T) {
+	day := func(year int, month time.Month, d int) time.Time {
+		return time.Date(year, month, d, 0, 0, 0, 0, time.UTC)
+	}
+	applications := []magnetis.Application{
+		{Date: day(2022, 1, 10), Type: magnetis.MoneyApplication, Investment: "CDB Banco Inter", Quantity: 1, Price: 1000},
+		{Date: day(2023, 3, 10), Type: magnetis.Redemption, Investment: "CDB Banco Inter", Quantity: 1, Price: 1100, IR: 15},
+		{Date: day(2022, 1, 10), Type: magnetis.MoneyApplication, Investment: "Garantia", Quantity: 1, Price: 500},
+		{Date: day(2023, 5, 10), Type: magnetis.Redemption, Investment: "Garantia", Quantity: 1, Price: 600},
+		{Date: day(2023, 6, 1), Type: magnetis.IRWithdrawal, Investment: "Fundo Sem Resgate", IR: 7},
+	}
+	assets := []magnetis.Asset{{Name: "Garantia", InstrumentTypeName: "LCI", Issuer: "Banco X"}}
+	cnpjs := map[string]string{"Banco Inter": "00.416.968/0001-01", "Banco X": "11.111.111/0001-11"}
+
+	r := AnnualReport(2023, applications, assets, cnpjs)
+	if !near(r.WithheldIR, 22) {
+		t.Errorf("WithheldIR = %v, want 22", r.WithheldIR)
+	}
+	if !near(r.ExemptIncome, 100) || !near(r.TaxedIncome, 85) {
+		t.Errorf("income exempt %v taxed %v, want 100 and 85", r.ExemptIncome, r.TaxedIncome)
+	}
+	want := map[string]Withholding{
+		"Banco Inter": {Issuer: "Banco Inter", CNPJ: "00.416.968/0001-01", IR: 15},
+		UnknownIssuer: {Issuer: UnknownIssuer, IR: 7},
+	}
+	if len(r.Withholdings) != len(want) {
+		t.Fatalf("Withholdings = %+v, want %+v", r.Withholdings, want)
+	}
+	for _, w := range r.Withholdings {
+		if w != want[w.Issuer] {
+			t.Errorf("Withholding %+v, want %+v", w, want[w.Issuer])
+		}
+	}
+}
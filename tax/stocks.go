@@ -0,0 +1,57 @@
+package tax
+
+import (
+	"fmt"
+	"time"
+
+	"github.com/alfredosegundo/magnetis-crawler/stocks"
+)
+
+// Stock sales rules for individuals on swing trade operations
+const (
+	StockSalesExemption = 20000.0
+	SwingTradeRate      = 0.15
+)
+
+// A MonthlyTax is the swing trade tax due on stock sales of a month.
+type MonthlyTax struct {
+	Month      time.Time
+	Sales      float64
+	Gain       float64
+	Exempt     bool    // Sales up to the exemption limit, gains are not taxed
+	Compensate float64 // Accumulated losses available after the month
+	Due        float64 // Paid with a DARF until the last business day of the next month
+}
+
+func (m MonthlyTax) String() string {
+	exempt := ""
+	if m.Exempt {
+		exempt = "exempt"
+	}
+	return fmt.Sprintf("%s\t%.2f\t%.2f\t%s\t%.2f\t%.2f", m.Month.Format("2006-01"), m.Sales, m.Gain, exempt, m.Compensate, m.Due)
+}
+
+// SwingTrade computes the tax due on each month of stock sales. Months
+// whose sales don't exceed the exemption have their gains exempted; losses
+// are accumulated and compensated against the taxable gains that follow.
+func SwingTrade(months []stocks.Month) (taxes []MonthlyTax) {
+	var losses float64
+	for _, m := range months {
+		t := MonthlyTax{Month: m.Month, Sales: m.Sales, Gain: m.Realized, Exempt: m.Sales <= StockSalesExemption}
+		switch {
+		case m.Realized < 0:
+			losses -= m.Realized
+		case !t.Exempt:
+			base := m.Realized - losses
+			if base > 0 {
+				t.Due = round(base * SwingTradeRate)
+				losses = 0
+			} else {
+				losses = -base
+			}
+		}
+		t.Compensate = losses
+		taxes = append(taxes, t)
+	}
+	return
+}
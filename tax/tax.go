@@ -0,0 +1,164 @@
+// Package tax implements the Brazilian taxation rules applied to fixed-income
+// redemptions: the regressive IR table, IOF for short redemptions and the
+// semiannual come-cotas charged on investment funds.
+package tax
+
+import (
+	"math"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/alfredosegundo/magnetis-crawler/calendar"
+)
+
+// iofTable holds the IOF rate charged on the gain for redemptions made on
+// the n-th day after the application (index 0 is day 1).
+var iofTable = [...]float64{
+	0.96, 0.93, 0.90, 0.86, 0.83, 0.80, 0.76, 0.73, 0.70, 0.66,
+	0.63, 0.60, 0.56, 0.53, 0.50, 0.46, 0.43, 0.40, 0.36, 0.33,
+	0.30, 0.26, 0.23, 0.20, 0.16, 0.13, 0.10, 0.06, 0.03, 0.00,
+}
+
+// Come-cotas rates for long and short term funds
+const (
+	ComeCotasLongTerm  = 0.15
+	ComeCotasShortTerm = 0.20
+)
+
+// HoldingDays returns the number of calendar days between the application
+// and the redemption.
+func HoldingDays(applied time.Time, redeemed time.Time) int {
+	a := time.Date(applied.Year(), applied.Month(), applied.Day(), 0, 0, 0, 0, time.UTC)
+	r := time.Date(redeemed.Year(), redeemed.Month(), redeemed.Day(), 0, 0, 0, 0, time.UTC)
+	return int(r.Sub(a).Hours() / 24)
+}
+
+// IRRate returns the regressive income tax rate for a fixed-income
+// investment held for the given number of days.
+func IRRate(days int) float64 {
+	switch {
+	case days <= 180:
+		return 0.225
+	case days <= 360:
+		return 0.20
+	case days <= 720:
+		return 0.175
+	default:
+		return 0.15
+	}
+}
+
+// IOFRate returns the IOF rate applied to the gain of redemptions made
+// within 30 days of the application.
+func IOFRate(days int) float64 {
+	if days < 1 {
+		return iofTable[0]
+	}
+	if days > len(iofTable) {
+		return 0
+	}
+	return iofTable[days-1]
+}
+
+// Exempt tells if the instrument is exempt of income tax for individuals.
+func Exempt(instrument string) bool {
+	upper := strings.ToUpper(instrument)
+	for _, prefix := range []string{"LCI", "LCA", "CRI", "CRA"} {
+		if strings.HasPrefix(upper, prefix) || strings.Contains(upper, " "+prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Instrument returns the name used to classify an investment, its
+// instrument type followed by its name; the type may be unknown.
+func Instrument(instrumentType, name string) string {
+	return strings.TrimSpace(strings.TrimSpace(instrumentType) + " " + strings.TrimSpace(name))
+}
+
+// Fund tells if the instrument is an investment fund, subject to come-cotas.
+func Fund(instrument string) bool {
+	upper := strings.ToUpper(instrument)
+	return strings.Contains(upper, "FUND") || strings.HasPrefix(upper, "FI ") || strings.HasPrefix(upper, "FIC ")
+}
+
+// A Redemption is a (possibly partial) liquidation of an investment.
+type Redemption struct {
+	Applied    time.Time // Day the money was applied
+	Redeemed   time.Time // Day the money was redeemed
+	Invested   float64   // Amount originally applied
+	Gross      float64   // Gross amount redeemed
+	Instrument string    // Instrument name or type, used to detect exemptions
+}
+
+// A Tax holds the taxes due on a Redemption.
+type Tax struct {
+	Days   int
+	Gain   float64
+	IOF    float64
+	IRRate float64
+	IR     float64
+	Net    float64
+}
+
+// Calculate applies IOF and the regressive IR table to a redemption.
+// IR is charged over the gain after IOF is deducted.
+func Calculate(r Redemption) (t Tax) {
+	t.Days = HoldingDays(r.Applied, r.Redeemed)
+	t.Gain = r.Gross - r.Invested
+	t.Net = r.Gross
+	if t.Gain <= 0 {
+		return
+	}
+	t.IOF = round(t.Gain * IOFRate(t.Days))
+	if !Exempt(r.Instrument) {
+		t.IRRate = IRRate(t.Days)
+		t.IR = round((t.Gain - t.IOF) * t.IRRate)
+	}
+	t.Net = r.Gross - t.IOF - t.IR
+	return
+}
+
+// ComeCotas returns the amount anticipated on a fund gain on a come-cotas date.
+func ComeCotas(gain float64, shortTerm bool) float64 {
+	if gain <= 0 {
+		return 0
+	}
+	if shortTerm {
+		return round(gain * ComeCotasShortTerm)
+	}
+	return round(gain * ComeCotasLongTerm)
+}
+
+// ComeCotasDates returns the come-cotas dates of a year: the last business
+// day of May and of November.
+func ComeCotasDates(year int) []time.Time {
+	return []time.Time{lastBusinessDay(year, time.May), lastBusinessDay(year, time.November)}
+}
+
+// NextComeCotas returns the first come-cotas date after t.
+func NextComeCotas(t time.Time) time.Time {
+	for year := t.Year(); ; year++ {
+		for _, d := range ComeCotasDates(year) {
+			if d.After(t) {
+				return d
+			}
+		}
+	}
+}
+
+func lastBusinessDay(year int, month time.Month) time.Time {
+	d := calendar.LastBusinessDay(year, month)
+	return time.Date(d.Year(), d.Month(), d.Day(), 0, 0, 0, 0, time.UTC)
+}
+
+func round(value float64) float64 {
+	return math.Round(value*100) / 100
+}
+
+func parseAmount(value string) float64 {
+	amount, _ := strconv.ParseFloat(strings.TrimSpace(value), 64)
+	return amount
+}
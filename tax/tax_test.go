@@ -0,0 +1,162 @@
+package tax
+
+import (
+	"math"
+	"testing"
+	"time"
+
+	"github.com/alfredosegundo/magnetis-crawler/magnetis"
+)
+
+func near(a, b float64) bool {
+	return math.Abs(a-b) < 1e-9
+}
+
+func TestIRRate(t *testing.T) {
+	tests := []struct {
+		days int
+		rate float64
+	}{
+		{0, 0.225},
+		{180, 0.225},
+		{181, 0.20},
+		{360, 0.20},
+		{361, 0.175},
+		{720, 0.175},
+		{721, 0.15},
+		{3000, 0.15},
+	}
+	for _, tt := range tests {
+		if got := IRRate(tt.days); got != tt.rate {
+			t.Errorf("IRRate(%d) = %v, want %v", tt.days, got, tt.rate)
+		}
+	}
+}
+
+func TestIOFRate(t *testing.T) {
+	tests := []struct {
+		days int
+		rate float64
+	}{
+		{0, 0.96},
+		{1, 0.96},
+		{2, 0.93},
+		{10, 0.66},
+		{15, 0.50},
+		{29, 0.03},
+		{30, 0},
+		{31, 0},
+	}
+	for _, tt := range tests {
+		if got := IOFRate(tt.days); got != tt.rate {
+			t.Errorf("IOFRate(%d) = %v, want %v", tt.days, got, tt.rate)
+		}
+	}
+}
+
+func TestCalculate(t *testing.T) {
+	applied := time.Date(2024, 1, 1, 0, 0, 0, 0, time.UTC)
+	tests := []struct {
+		name       string
+		days       int
+		invested   float64
+		gross      float64
+		instrument string
+		iof, ir    float64
+		net        float64
+	}{
+		{"loss", 200, 1000, 900, "CDB", 0, 0, 900},
+		{"no gain", 200, 1000, 1000, "CDB", 0, 0, 1000},
+		{"same day", 0, 1000, 1010, "CDB", 9.6, 0.09, 1000.31},
+		{"iof", 15, 1000, 1100, "CDB", 50, 11.25, 1038.75},
+		{"180 days", 180, 1000, 1100, "CDB", 0, 22.5, 1077.5},
+		{"181 days", 181, 1000, 1100, "CDB", 0, 20, 1080},
+		{"360 days", 360, 1000, 1100, "CDB", 0, 20, 1080},
+		{"361 days", 361, 1000, 1100, "CDB", 0, 17.5, 1082.5},
+		{"720 days", 720, 1000, 1100, "CDB", 0, 17.5, 1082.5},
+		{"721 days", 721, 1000, 1100, "CDB", 0, 15, 1085},
+		{"exempt", 100, 1000, 1100, "LCI Banco", 0, 0, 1100},
+		{"exempt with iof", 15, 1000, 1100, "LCA Banco", 50, 0, 1050},
+	}
+	for _, tt := range tests {
+		got := Calculate(Redemption{
+			Applied:    applied,
+			Redeemed:   applied.AddDate(0, 0, tt.days),
+			Invested:   tt.invested,
+			Gross:      tt.gross,
+			Instrument: tt.instrument,
+		})
+		if got.Days != tt.days || !near(got.IOF, tt.iof) || !near(got.IR, tt.ir) || !near(got.Net, tt.net) {
+			t.Errorf("%s: got days %d IOF %v IR %v net %v, want days %d IOF %v IR %v net %v",
+				tt.name, got.Days, got.IOF, got.IR, got.Net, tt.days, tt.iof, tt.ir, tt.net)
+		}
+	}
+}
+
+func TestComeCotas(t *testing.T) {
+	tests := []struct {
+		gain      float64
+		shortTerm bool
+		want      float64
+	}{
+		{100, false, 15},
+		{100, true, 20},
+		{0, false, 0},
+		{-50, true, 0},
+	}
+	for _, tt := range tests {
+		if got := ComeCotas(tt.gain, tt.shortTerm); !near(got, tt.want) {
+			t.Errorf("ComeCotas(%v, %v) = %v, want %v", tt.gain, tt.shortTerm, got, tt.want)
+		}
+	}
+}
+
+func TestComeCotasDates(t *testing.T) {
+	tests := []struct {
+		year int
+		want []string
+	}{
+		{2024, []string{"2024-05-31", "2024-11-29"}},
+		{2025, []string{"2025-05-30", "2025-11-28"}},
+	}
+	for _, tt := range tests {
+		dates := ComeCotasDates(tt.year)
+		for i, d := range dates {
+			if got := d.Format("2006-01-02"); got != tt.want[i] {
+				t.Errorf("ComeCotasDates(%d)[%d] = %s, want %s", tt.year, i, got, tt.want[i])
+			}
+		}
+	}
+	next := NextComeCotas(time.Date(2024, 6, 1, 0, 0, 0, 0, time.UTC))
+	if got := next.Format("2006-01-02"); got != "2024-11-29" {
+		t.Errorf("NextComeCotas = %s, want 2024-11-29", got)
+	}
+}
+
+func TestEstimateLiquidation(t *testing.T) {
+	now := time.Date(2024, 7, 1, 0, 0, 0, 0, time.UTC)
+	assets := []magnetis.Asset{
+		{Name: "CDB Banco", InstrumentTypeName: "CDB", Amount: "1100", AssetReturn: "100"},
+		{Name: "CDB Sem Historico", InstrumentTypeName: "CDB", Amount: "1100", AssetReturn: "100"},
+		{Name: "LCI Sem Historico", InstrumentTypeName: "LCI", Amount: "1100", AssetReturn: "100"},
+	}
+	applications := []magnetis.Application{
+		{Date: now.AddDate(0, 0, -400), Type: magnetis.MoneyApplication, Investment: "CDB Banco", Quantity: 1, Price: 1000},
+	}
+	estimates := EstimateLiquidation(assets, applications, now)
+	tests := []struct {
+		ir           float64
+		approximated bool
+	}{
+		{17.5, false},
+		{22.5, true},
+		{0, true},
+	}
+	for i, tt := range tests {
+		e := estimates[i]
+		if !near(e.IR, tt.ir) || e.IOF != 0 || e.Approximated != tt.approximated {
+			t.Errorf("%s: got IR %v IOF %v approximated %v, want IR %v IOF 0 approximated %v",
+				e.Asset.Name, e.IR, e.IOF, e.Approximated, tt.ir, tt.approximated)
+		}
+	}
+}